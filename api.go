@@ -4,6 +4,8 @@
 
 package modbus
 
+import "context"
+
 type Client interface {
 	// A bit of access
 
@@ -46,4 +48,160 @@ type Client interface {
 	//ReadFIFOQueue reads the contents of a First-In-First-Out (FIFO) queue
 	// of register in a remote device and returns FIFO value register.
 	ReadFIFOQueue(address uint16) (results []byte, err error)
+
+	// Diagnostics (serial line only)
+
+	// ReadExceptionStatus reads the contents of eight exception status
+	// outputs in a remote device.
+	ReadExceptionStatus() (status byte, err error)
+	// Diagnostics performs a diagnostics sub-function and returns the
+	// data of the response following the echoed sub-function.
+	Diagnostics(subFunction uint16, data []byte) (results []byte, err error)
+	// ReturnQueryData asks the remote device to echo data back.
+	ReturnQueryData(data []byte) (results []byte, err error)
+	// RestartCommunications restarts the serial line port of the remote
+	// device and brings it out of listen only mode, optionally clearing
+	// its communications event log.
+	RestartCommunications(clearEventLog bool) (err error)
+	// ClearCounters clears all counters and the diagnostic register.
+	ClearCounters() (err error)
+	// ForceListenOnlyMode isolates the remote device from the other
+	// devices on the bus. The device does not answer this request.
+	ForceListenOnlyMode() (err error)
+	// ReadDiagnosticCounter returns the diagnostic register or the
+	// counter selected by a DiagSubFuncReturn* sub-function.
+	ReadDiagnosticCounter(subFunction uint16) (count uint16, err error)
+	// GetCommEventCounter returns a status word and the event count of
+	// the communications event counter of a remote device.
+	GetCommEventCounter() (result *CommEventCounter, err error)
+	// GetCommEventLog returns a status word, the event count, the message
+	// count and up to 64 event bytes from a remote device.
+	GetCommEventLog() (result *CommEventLog, err error)
+	// ReportServerId reads the description of the type, the current status
+	// and other information specific to a remote device.
+	ReportServerId() (result *ServerIdReport, err error)
+
+	// File record access
+
+	// ReadFileRecord reads one record per sub-request, using RecordLength
+	// of each, and returns the record data in the same order.
+	ReadFileRecord(records []FileRecord) (results [][]byte, err error)
+	// WriteFileRecord writes the Data of each sub-request.
+	WriteFileRecord(records []FileRecord) (err error)
+
+	// Device identification
+
+	// ReadDeviceIdentification reads the identification objects of a
+	// remote device, starting at objectId, and returns their values by
+	// object id. Objects not fitting in a single response are fetched
+	// with follow-up requests.
+	ReadDeviceIdentification(readDeviceIdCode, objectId byte) (results map[byte][]byte, err error)
+
+	// Raw access
+
+	// SendPDU sends a request with any function code, e.g. user defined
+	// or vendor specific ones, and returns the data of the response.
+	// Exception responses are returned as *ModbusError. For RTU, the
+	// response length of codes unknown to the package must be registered
+	// with RegisterResponseLength.
+	SendPDU(functionCode byte, data []byte) (results []byte, err error)
+}
+
+// ClientContext is a Client whose requests can be canceled. Each method
+// behaves as its Client counterpart but aborts the transaction, including
+// dialing and reading the response, when ctx is done. A request waiting for
+// the transaction of another goroutine to complete is not aborted, nor is
+// the query delay which follows a transaction.
+type ClientContext interface {
+	Client
+
+	// A bit of access
+
+	ReadCoilsContext(ctx context.Context, address, quantity uint16) (results []byte, err error)
+	ReadDiscreteInputsContext(ctx context.Context, address, quantity uint16) (results []byte, err error)
+	WriteSingleCoilContext(ctx context.Context, address, value uint16) (results []byte, err error)
+	WriteMultipleCoilsContext(ctx context.Context, address, quantity uint16, value []byte) (results []byte, err error)
+
+	// 16-bit access
+
+	ReadInputRegistersContext(ctx context.Context, address, quantity uint16) (results []byte, err error)
+	ReadHoldingRegistersContext(ctx context.Context, address, quantity uint16) (results []byte, err error)
+	WriteSingleRegisterContext(ctx context.Context, address, value uint16) (results []byte, err error)
+	WriteMultipleRegistersContext(ctx context.Context, address, quantity uint16, value []byte) (results []byte, err error)
+	ReadWriteMultipleRegistersContext(ctx context.Context, readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) (results []byte, err error)
+	MaskWriteRegisterContext(ctx context.Context, address, andMask, orMask uint16) (results []byte, err error)
+	ReadFIFOQueueContext(ctx context.Context, address uint16) (results []byte, err error)
+
+	// Diagnostics (serial line only)
+
+	ReadExceptionStatusContext(ctx context.Context) (status byte, err error)
+	DiagnosticsContext(ctx context.Context, subFunction uint16, data []byte) (results []byte, err error)
+	ReturnQueryDataContext(ctx context.Context, data []byte) (results []byte, err error)
+	RestartCommunicationsContext(ctx context.Context, clearEventLog bool) (err error)
+	ClearCountersContext(ctx context.Context) (err error)
+	ForceListenOnlyModeContext(ctx context.Context) (err error)
+	ReadDiagnosticCounterContext(ctx context.Context, subFunction uint16) (count uint16, err error)
+	GetCommEventCounterContext(ctx context.Context) (result *CommEventCounter, err error)
+	GetCommEventLogContext(ctx context.Context) (result *CommEventLog, err error)
+	ReportServerIdContext(ctx context.Context) (result *ServerIdReport, err error)
+
+	// File record access
+
+	ReadFileRecordContext(ctx context.Context, records []FileRecord) (results [][]byte, err error)
+	WriteFileRecordContext(ctx context.Context, records []FileRecord) (err error)
+
+	// Device identification
+
+	ReadDeviceIdentificationContext(ctx context.Context, readDeviceIdCode, objectId byte) (results map[byte][]byte, err error)
+
+	// Raw access
+
+	SendPDUContext(ctx context.Context, functionCode byte, data []byte) (results []byte, err error)
+
+	// Addressing
+
+	// WithSlave returns a view of the client sending its requests to
+	// slaveId instead of the slave id of the handler, which is left
+	// untouched. Views share the transporter, so their requests are still
+	// serialised on the same port, and may be used concurrently.
+	WithSlave(slaveId byte) ClientContext
+}
+
+// CommEventCounter is the result of GetCommEventCounter.
+type CommEventCounter struct {
+	// 0xFFFF while a previous command is still being processed
+	Status     uint16
+	EventCount uint16
+}
+
+// CommEventLog is the result of GetCommEventLog.
+type CommEventLog struct {
+	// 0xFFFF while a previous command is still being processed
+	Status       uint16
+	EventCount   uint16
+	MessageCount uint16
+	// Events, the most recent first
+	Events []byte
+}
+
+// ServerIdReport is the result of ReportServerId. ServerId, RunIndicatorStatus
+// and AdditionalData assume the common one byte server id, Data holds the
+// device specific bytes as received.
+type ServerIdReport struct {
+	ServerId           byte
+	RunIndicatorStatus bool
+	AdditionalData     []byte
+	Data               []byte
+}
+
+// FileRecord is a sub-request of ReadFileRecord and WriteFileRecord.
+type FileRecord struct {
+	// File number, from 1
+	FileNumber uint16
+	// Starting record (register) in the file, from 0 to 9999
+	RecordNumber uint16
+	// Number of records to read, ignored when writing
+	RecordLength uint16
+	// Record data to write, 2 bytes per record
+	Data []byte
 }
@@ -0,0 +1,291 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	asciiStart   = ":"
+	asciiEnd     = "\r\n"
+	asciiMinSize = 3
+	asciiMaxSize = 513
+
+	hexTable = "0123456789ABCDEF"
+)
+
+// ASCIIClientHandler implements Packager and Transporter interface.
+type ASCIIClientHandler struct {
+	AsciiPackager
+	asciiSerialTransporter
+}
+
+// NewASCIIClientHandler allocates and initializes a ASCIIClientHandler.
+func NewASCIIClientHandler(address string) *ASCIIClientHandler {
+	handler := &ASCIIClientHandler{}
+	handler.Address = address
+	handler.Timeout = serialTimeout
+	handler.IdleTimeout = serialIdleTimeout
+	handler.TurnaroundDelay = serialTurnaroundDelay
+	return handler
+}
+
+// AsciiPackager implements Packager interface.
+type AsciiPackager struct {
+	basePackager
+	SlaveId byte
+}
+
+func (mb *AsciiPackager) slaveId() byte {
+	return mb.SlaveId
+}
+
+func (mb *AsciiPackager) broadcasts() bool {
+	return true
+}
+
+// Encode encodes PDU in a ASCII frame:
+//
+//	Start           : 1 char
+//	Address         : 2 chars
+//	Function        : 2 chars
+//	Data            : 0 up to 2x252 chars
+//	LRC             : 2 chars
+//	End             : 2 chars
+func (mb *AsciiPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
+	return mb.encodeSlave(pdu, mb.SlaveId)
+}
+
+// encodeSlave is Encode addressing the PDU to slaveId.
+func (mb *AsciiPackager) encodeSlave(pdu *ProtocolDataUnit, slaveId byte) (adu []byte, err error) {
+	var buf bytes.Buffer
+
+	if _, err = buf.WriteString(asciiStart); err != nil {
+		return
+	}
+	if err = writeHex(&buf, []byte{slaveId, pdu.FunctionCode}); err != nil {
+		return
+	}
+	if err = writeHex(&buf, pdu.Data); err != nil {
+		return
+	}
+	// Exclude the beginning colon and terminating CRLF pair characters
+	var lrc lrc
+	lrc.reset().pushByte(slaveId).pushByte(pdu.FunctionCode).pushBytes(pdu.Data)
+	if err = writeHex(&buf, []byte{lrc.value()}); err != nil {
+		return
+	}
+	if _, err = buf.WriteString(asciiEnd); err != nil {
+		return
+	}
+	adu = buf.Bytes()
+	return
+}
+
+// Verify verifies response length, frame boundary and slave id.
+func (mb *AsciiPackager) Verify(aduRequest []byte, aduResponse []byte) (err error) {
+	length := len(aduResponse)
+	// Minimum size (including address, function and LRC)
+	if length < asciiMinSize+6 {
+		err = fmt.Errorf("modbus: response length '%v' does not meet minimum '%v'", length, asciiMinSize+6)
+		return
+	}
+	// Length excluding colon must be an even number
+	if length%2 != 1 {
+		err = fmt.Errorf("modbus: response length '%v' is not an even number", length-1)
+		return
+	}
+	// First char must be a colon
+	str := string(aduResponse[0:len(asciiStart)])
+	if str != asciiStart {
+		err = fmt.Errorf("modbus: response frame '%x'... is not started with '%x'", str, asciiStart)
+		return
+	}
+	// 2 last chars must be \r\n
+	str = string(aduResponse[len(aduResponse)-len(asciiEnd):])
+	if str != asciiEnd {
+		err = fmt.Errorf("modbus: response frame ...'%x' is not ended with '%x'", str, asciiEnd)
+		return
+	}
+	// Slave id
+	responseVal, err := readHex(aduResponse[1:])
+	if err != nil {
+		return
+	}
+	requestVal, err := readHex(aduRequest[1:])
+	if err != nil {
+		return
+	}
+	if responseVal != requestVal {
+		err = fmt.Errorf("modbus: response slave id '%v' does not match request '%v'", responseVal, requestVal)
+		return
+	}
+	return
+}
+
+// Decode extracts PDU from ASCII frame and verify LRC.
+func (mb *AsciiPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
+	pdu = &ProtocolDataUnit{}
+	// Slave address
+	address, err := readHex(adu[1:])
+	if err != nil {
+		return
+	}
+	// Function code
+	if pdu.FunctionCode, err = readHex(adu[3:]); err != nil {
+		return
+	}
+	// Data
+	dataEnd := len(adu) - 4
+	data := adu[5:dataEnd]
+	pdu.Data = make([]byte, hex.DecodedLen(len(data)))
+	if _, err = hex.Decode(pdu.Data, data); err != nil {
+		return
+	}
+	// LRC
+	lrcVal, err := readHex(adu[dataEnd:])
+	if err != nil {
+		return
+	}
+	// Calculate checksum
+	var lrc lrc
+	lrc.reset().pushByte(address).pushByte(pdu.FunctionCode).pushBytes(pdu.Data)
+	if lrcVal != lrc.value() {
+		err = &ChecksumError{Name: "lrc", Received: uint16(lrcVal), Expected: uint16(lrc.value())}
+		return
+	}
+	return
+}
+
+// asciiSerialTransporter implements Transporter interface.
+type asciiSerialTransporter struct {
+	SerialPort
+}
+
+func (mb *asciiSerialTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done.
+func (mb *asciiSerialTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	mb.Mu.Lock()
+	defer func() {
+		if mb.QueryDelayDuration > 0 {
+			time.Sleep(mb.QueryDelayDuration)
+		}
+		mb.Mu.Unlock()
+	}()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	// Make sure port is connected
+	if err = mb.ConnectContext(ctx); err != nil {
+		return
+	}
+	// Start the timer to close when idle
+	mb.LastActivity = time.Now()
+	mb.StartCloseTimer()
+
+	// The serial port has no deadline, closing it aborts pending I/O when ctx is done
+	conn := mb.Conn
+	stop := watchContext(ctx, func() { _ = conn.Close() })
+	defer func() {
+		if interrupted := stop(); interrupted || (err != nil && ctx.Err() != nil) {
+			_ = mb.ConnClose()
+			if err != nil {
+				err = ctx.Err()
+			}
+		}
+	}()
+
+	// Send the request
+	mb.Debugf("modbus: sending %q", aduRequest)
+	if _, err = mb.Conn.Write(aduRequest); err != nil {
+		_ = mb.ConnClose()
+		return
+	}
+	if !asciiResponseExpected(aduRequest) {
+		if string(aduRequest[1:3]) == "00" {
+			err = sleepContext(ctx, mb.TurnaroundDelay)
+		}
+		return
+	}
+	// Get the response
+	if aduResponse, err = readASCIIFrame(mb.Conn); err != nil {
+		return
+	}
+	mb.Debugf("modbus: received %q", aduResponse)
+	return
+}
+
+// toleratesMismatch keeps the serial port open when a response fails
+// verification: a response from another slave is a fault of the line,
+// which reopening the port does not cure.
+func (mb *asciiSerialTransporter) toleratesMismatch() bool {
+	return true
+}
+
+// readASCIIFrame reads from r until the frame terminator is received
+// or the maximum frame size is reached.
+func readASCIIFrame(r io.Reader) (frame []byte, err error) {
+	var n int
+	var data [asciiMaxSize]byte
+	length := 0
+	for {
+		if n, err = r.Read(data[length:]); err != nil {
+			return
+		}
+		length += n
+		if length >= asciiMaxSize || n == 0 {
+			break
+		}
+		// Expect end of frame in the data received
+		if length > asciiMinSize {
+			if string(data[length-len(asciiEnd):length]) == asciiEnd {
+				break
+			}
+		}
+	}
+	frame = data[:length]
+	return
+}
+
+// asciiResponseExpected reports whether the request adu is answered,
+// which is not the case for broadcast requests and when a device is forced
+// into listen only mode.
+func asciiResponseExpected(adu []byte) bool {
+	if len(adu) < 9 {
+		return true
+	}
+	return string(adu[1:3]) != "00" && string(adu[3:9]) != "080004"
+}
+
+// writeHex encodes byte to string in hexadecimal, e.g. 0xA5 => "A5"
+// (encoding/hex only supports lowercase string).
+func writeHex(buf *bytes.Buffer, value []byte) (err error) {
+	var str [2]byte
+	for _, v := range value {
+		str[0] = hexTable[v>>4]
+		str[1] = hexTable[v&0x0F]
+
+		if _, err = buf.Write(str[:]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// readHex decodes hexa string to byte, e.g. "8C" => 0x8C.
+func readHex(data []byte) (value byte, err error) {
+	var dst [1]byte
+	if _, err = hex.Decode(dst[:], data[0:2]); err != nil {
+		return
+	}
+	value = dst[0]
+	return
+}
@@ -0,0 +1,72 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAsciiPackagerEncode(t *testing.T) {
+	packager := &AsciiPackager{SlaveId: 0x11}
+	adu, err := packager.Encode(&ProtocolDataUnit{
+		FunctionCode: FuncCodeReadHoldingRegisters,
+		Data:         []byte{0x00, 0x6B, 0x00, 0x03},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := ":1103006B00037E\r\n"; string(adu) != expected {
+		t.Fatalf("adu %q, expected %q", adu, expected)
+	}
+}
+
+func TestAsciiPackagerVerifyDecode(t *testing.T) {
+	request := []byte(":1103006B00037E\r\n")
+	tests := []struct {
+		name     string
+		response string
+		// Verify fails
+		invalid bool
+		// Decode fails with a checksum error
+		checksum bool
+		data     []byte
+	}{
+		{name: "known frame", response: ":110306022B0000006455\r\n", data: []byte{0x06, 0x02, 0x2B, 0x00, 0x00, 0x00, 0x64}},
+		{name: "exception", response: ":1183026A\r\n", data: []byte{0x02}},
+		{name: "bad lrc", response: ":110306022B0000006456\r\n", checksum: true},
+		{name: "odd length hex", response: ":110306022B000000645\r\n", invalid: true},
+		{name: "missing crlf", response: ":110306022B0000006455", invalid: true},
+		{name: "missing colon", response: ";110306022B0000006455\r\n", invalid: true},
+		{name: "too short", response: ":11\r\n", invalid: true},
+		{name: "other slave", response: ":120306022B0000006454\r\n", invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var packager AsciiPackager
+			err := packager.Verify(request, []byte(test.response))
+			if test.invalid {
+				if err == nil {
+					t.Fatal("verify must fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			pdu, err := packager.Decode([]byte(test.response))
+			var checksumError *ChecksumError
+			if test.checksum {
+				if !errors.As(err, &checksumError) {
+					t.Fatalf("error '%v' must be a checksum error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(pdu.Data, test.data) {
+				t.Fatalf("data % x, expected % x", pdu.Data, test.data)
+			}
+		})
+	}
+}
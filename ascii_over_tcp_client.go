@@ -0,0 +1,94 @@
+package modbus
+
+import (
+	"context"
+	"time"
+)
+
+// ASCIIOverTcpClientHandler implements Packager and Transporter interface.
+type ASCIIOverTcpClientHandler struct {
+	AsciiPackager
+	asciiOverTcpTransporter
+}
+
+// NewASCIIOverTcpClientHandler allocates and initializes a ASCIIOverTcpClientHandler.
+func NewASCIIOverTcpClientHandler(address string) *ASCIIOverTcpClientHandler {
+	handler := &ASCIIOverTcpClientHandler{}
+	handler.Address = address
+	handler.Timeout = tcpTimeout
+	handler.IdleTimeout = tcpIdleTimeout
+	handler.TurnaroundDelay = serialTurnaroundDelay
+	return handler
+}
+
+// asciiOverTcpTransporter implements Transporter interface.
+type asciiOverTcpTransporter struct {
+	TcpPort
+	// Delay after a broadcast request, giving the slaves time to process it
+	TurnaroundDelay time.Duration
+}
+
+func (mb *asciiOverTcpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done.
+func (mb *asciiOverTcpTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	mb.Mu.Lock()
+	defer func() {
+		if mb.QueryDelayDuration > 0 {
+			time.Sleep(mb.QueryDelayDuration)
+		}
+		mb.Mu.Unlock()
+	}()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	// Make sure port is connected
+	if err = mb.ConnectContext(ctx); err != nil {
+		return
+	}
+	// Start the timer to close when idle
+	mb.LastActivity = time.Now()
+	mb.StartCloseTimer()
+
+	// Set write and read timeout
+	var timeout time.Time
+	if mb.Timeout > 0 {
+		timeout = mb.LastActivity.Add(mb.Timeout)
+	}
+	if err = mb.Conn.SetDeadline(contextDeadline(ctx, timeout)); err != nil {
+		_ = mb.ConnClose()
+		return
+	}
+	// Abort pending I/O when ctx is done
+	conn := mb.Conn
+	stop := watchContext(ctx, func() { _ = conn.SetDeadline(aLongTimeAgo) })
+	defer func() {
+		if interrupted := stop(); err != nil && (interrupted || ctx.Err() != nil) {
+			// The connection is left in an unknown state
+			_ = mb.ConnClose()
+			err = ctx.Err()
+		}
+	}()
+
+	// Send the request
+	mb.Debugf("modbus: sending %q", aduRequest)
+	if _, err = mb.Conn.Write(aduRequest); err != nil {
+		_ = mb.ConnClose()
+		return
+	}
+	if !asciiResponseExpected(aduRequest) {
+		if string(aduRequest[1:3]) == "00" {
+			err = sleepContext(ctx, mb.TurnaroundDelay)
+		}
+		return
+	}
+	// Get the response
+	if aduResponse, err = readASCIIFrame(mb.Conn); err != nil {
+		return
+	}
+	mb.Debugf("modbus: received %q", aduResponse)
+	return
+}
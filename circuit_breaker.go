@@ -0,0 +1,218 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	circuitFailureThreshold = 3
+	circuitOpenTimeout      = 30 * time.Second
+)
+
+// CircuitState is the state of the circuit of a slave.
+type CircuitState int
+
+const (
+	// Requests are sent
+	CircuitClosed CircuitState = iota
+	// Requests fail without being sent
+	CircuitOpen
+	// A single probe request is sent, deciding whether to close the circuit
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(state))
+}
+
+// CircuitOpenError is returned without sending the request when the
+// circuit of the slave is open.
+type CircuitOpenError struct {
+	SlaveId byte
+	// Time the next probe request is allowed
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("modbus: circuit of slave '%v' is open until '%v'", e.SlaveId, e.Until.Format(time.RFC3339Nano))
+}
+
+// SlaveStatus is the state of the circuit of a slave.
+type SlaveStatus struct {
+	SlaveId byte
+	State   CircuitState
+	// Number of consecutive failures
+	Failures int
+	// Last failure
+	LastError error
+	// Time the next probe request is allowed when the circuit is open
+	Until time.Time
+}
+
+// CircuitBreaker stops sending requests to the slaves which repeatedly fail
+// to respond, so that a dead device on a shared line does not delay the
+// requests to the others. The circuit of a slave opens after consecutive
+// failures, then a probe request is let through every OpenTimeout until
+// one succeeds. Only timeouts, checksum mismatches and the gateway
+// exceptions count as failures: other exceptions are responses, and errors
+// encoding the request or connecting say nothing of the slave.
+// A CircuitBreaker may be shared by the clients of a line.
+type CircuitBreaker struct {
+	// Number of consecutive failures opening the circuit
+	FailureThreshold int
+	// Time the circuit stays open before a probe request
+	OpenTimeout time.Duration
+
+	mu     sync.Mutex
+	slaves map[byte]*SlaveStatus
+}
+
+// NewCircuitBreaker allocates a CircuitBreaker.
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenTimeout:      openTimeout,
+	}
+}
+
+// WithCircuitBreaker makes the client fail fast with a CircuitOpenError
+// when the circuit of the slave is open. Broadcast requests are not
+// subject to the breaker.
+func WithCircuitBreaker(breaker *CircuitBreaker) ClientOption {
+	return func(mb *client) {
+		mb.breaker = breaker
+	}
+}
+
+// Status returns the state of the circuit of the slave.
+func (cb *CircuitBreaker) Status(slaveId byte) SlaveStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if status, ok := cb.slaves[slaveId]; ok {
+		return *status
+	}
+	return SlaveStatus{SlaveId: slaveId}
+}
+
+// Statuses returns the state of the circuits of the slaves which received
+// requests, by slave id.
+func (cb *CircuitBreaker) Statuses() (statuses []SlaveStatus) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	for _, status := range cb.slaves {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].SlaveId < statuses[j].SlaveId
+	})
+	return
+}
+
+// Reset closes the circuit of the slave.
+func (cb *CircuitBreaker) Reset(slaveId byte) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	delete(cb.slaves, slaveId)
+}
+
+// allow reports with an error whether a request may be sent to the slave.
+// When the open timeout has passed, the request becomes the probe.
+func (cb *CircuitBreaker) allow(slaveId byte) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	status, ok := cb.slaves[slaveId]
+	if !ok {
+		return nil
+	}
+	switch status.State {
+	case CircuitOpen:
+		if time.Now().Before(status.Until) {
+			return &CircuitOpenError{SlaveId: slaveId, Until: status.Until}
+		}
+		status.State = CircuitHalfOpen
+	case CircuitHalfOpen:
+		// The probe is pending
+		return &CircuitOpenError{SlaveId: slaveId, Until: status.Until}
+	}
+	return nil
+}
+
+// record updates the circuit of the slave with the outcome of a request.
+func (cb *CircuitBreaker) record(slaveId byte, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	failed := slaveFailed(err)
+	var mbError *ModbusError
+	if err == nil || (!failed && errors.As(err, &mbError)) {
+		delete(cb.slaves, slaveId)
+		return
+	}
+	status, ok := cb.slaves[slaveId]
+	if !failed {
+		// No verdict, let the next request probe
+		if ok && status.State == CircuitHalfOpen {
+			status.State = CircuitOpen
+		}
+		return
+	}
+	if !ok {
+		if cb.slaves == nil {
+			cb.slaves = make(map[byte]*SlaveStatus)
+		}
+		status = &SlaveStatus{SlaveId: slaveId}
+		cb.slaves[slaveId] = status
+	}
+	status.Failures++
+	status.LastError = err
+	threshold := cb.FailureThreshold
+	if threshold <= 0 {
+		threshold = circuitFailureThreshold
+	}
+	if status.State == CircuitHalfOpen || status.Failures >= threshold {
+		openTimeout := cb.OpenTimeout
+		if openTimeout <= 0 {
+			openTimeout = circuitOpenTimeout
+		}
+		status.State = CircuitOpen
+		status.Until = time.Now().Add(openTimeout)
+	}
+}
+
+// slaveFailed reports whether err shows the slave failed to respond: a
+// timeout, a checksum mismatch or a gateway exception.
+func slaveFailed(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var mbError *ModbusError
+	if errors.As(err, &mbError) {
+		return mbError.ExceptionCode == ExceptionCodeGatewayPathUnavailable ||
+			mbError.ExceptionCode == ExceptionCodeGatewayTargetDeviceFailedToRespond
+	}
+	// Dialing the device or the gateway, the request did not reach the slave
+	var opError *net.OpError
+	if errors.As(err, &opError) && opError.Op == "dial" {
+		return false
+	}
+	var checksumError *ChecksumError
+	return errors.As(err, &checksumError) || isTimeout(err)
+}
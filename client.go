@@ -5,6 +5,8 @@
 package modbus
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 )
@@ -15,19 +17,41 @@ type ClientHandler interface {
 	Transporter
 }
 
+const (
+	fileRecordReferenceType = 6
+	fileRecordMaxNumber     = 9999
+	// Maximum size of the data following the function code in a PDU
+	fileRecordMaxLength = 252
+	// Maximum size of the sub-requests of a read file record request and of
+	// the sub-responses of its response, following the byte count
+	fileReadMaxLength = 0xF5
+)
+
 type client struct {
 	packager    Packager
 	transporter Transporter
+	retry       *RetryPolicy
+	breaker     *CircuitBreaker
+	// Slave id overriding the one of the packager, if hasSlave
+	slave    byte
+	hasSlave bool
 }
 
+// ClientOption configures a client created by NewClient or NewClient2.
+type ClientOption func(mb *client)
+
 // NewClient creates a new modbus client with given backend handler.
-func NewClient(handler ClientHandler) Client {
-	return &client{packager: handler, transporter: handler}
+func NewClient(handler ClientHandler, options ...ClientOption) ClientContext {
+	return NewClient2(handler, handler, options...)
 }
 
 // NewClient2 creates a new modbus client with given backend packager and transporter.
-func NewClient2(packager Packager, transporter Transporter) Client {
-	return &client{packager: packager, transporter: transporter}
+func NewClient2(packager Packager, transporter Transporter, options ...ClientOption) ClientContext {
+	mb := &client{packager: packager, transporter: transporter}
+	for _, option := range options {
+		option(mb)
+	}
+	return mb
 }
 
 // ReadCoils Request:
@@ -42,15 +66,20 @@ func NewClient2(packager Packager, transporter Transporter) Client {
 //	Byte count            : 1 byte
 //	Coil status           : N* bytes (=N or N+1)
 func (mb *client) ReadCoils(address, quantity uint16) (results []byte, err error) {
-	if quantity < 1 || quantity > 2000 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 2000)
+	return mb.ReadCoilsContext(context.Background(), address, quantity)
+}
+
+// ReadCoilsContext is ReadCoils bounded by ctx.
+func (mb *client) ReadCoilsContext(ctx context.Context, address, quantity uint16) (results []byte, err error) {
+	if quantity < 1 || quantity > MaxReadBits {
+		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, MaxReadBits)
 		return
 	}
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeReadCoils,
 		Data:         mb.packager.DataBlock(address, quantity),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -80,15 +109,20 @@ func (mb *client) ReadCoils(address, quantity uint16) (results []byte, err error
 //	Byte count            : 1 byte
 //	Input status          : N* bytes (=N or N+1)
 func (mb *client) ReadDiscreteInputs(address, quantity uint16) (results []byte, err error) {
-	if quantity < 1 || quantity > 2000 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 2000)
+	return mb.ReadDiscreteInputsContext(context.Background(), address, quantity)
+}
+
+// ReadDiscreteInputsContext is ReadDiscreteInputs bounded by ctx.
+func (mb *client) ReadDiscreteInputsContext(ctx context.Context, address, quantity uint16) (results []byte, err error) {
+	if quantity < 1 || quantity > MaxReadBits {
+		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, MaxReadBits)
 		return
 	}
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeReadDiscreteInputs,
 		Data:         mb.packager.DataBlock(address, quantity),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -118,15 +152,20 @@ func (mb *client) ReadDiscreteInputs(address, quantity uint16) (results []byte,
 //	Byte count            : 1 byte
 //	Register value        : Nx2 bytes
 func (mb *client) ReadHoldingRegisters(address, quantity uint16) (results []byte, err error) {
-	if quantity < 1 || quantity > 125 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 125)
+	return mb.ReadHoldingRegistersContext(context.Background(), address, quantity)
+}
+
+// ReadHoldingRegistersContext is ReadHoldingRegisters bounded by ctx.
+func (mb *client) ReadHoldingRegistersContext(ctx context.Context, address, quantity uint16) (results []byte, err error) {
+	if quantity < 1 || quantity > MaxReadRegisters {
+		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, MaxReadRegisters)
 		return
 	}
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeReadHoldingRegisters,
 		Data:         mb.packager.DataBlock(address, quantity),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -156,15 +195,20 @@ func (mb *client) ReadHoldingRegisters(address, quantity uint16) (results []byte
 //	Byte count            : 1 byte
 //	Input registers       : N bytes
 func (mb *client) ReadInputRegisters(address, quantity uint16) (results []byte, err error) {
-	if quantity < 1 || quantity > 125 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 125)
+	return mb.ReadInputRegistersContext(context.Background(), address, quantity)
+}
+
+// ReadInputRegistersContext is ReadInputRegisters bounded by ctx.
+func (mb *client) ReadInputRegistersContext(ctx context.Context, address, quantity uint16) (results []byte, err error) {
+	if quantity < 1 || quantity > MaxReadRegisters {
+		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, MaxReadRegisters)
 		return
 	}
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeReadInputRegisters,
 		Data:         mb.packager.DataBlock(address, quantity),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -194,6 +238,11 @@ func (mb *client) ReadInputRegisters(address, quantity uint16) (results []byte,
 //	Output address        : 2 bytes
 //	Output value          : 2 bytes
 func (mb *client) WriteSingleCoil(address, value uint16) (results []byte, err error) {
+	return mb.WriteSingleCoilContext(context.Background(), address, value)
+}
+
+// WriteSingleCoilContext is WriteSingleCoil bounded by ctx.
+func (mb *client) WriteSingleCoilContext(ctx context.Context, address, value uint16) (results []byte, err error) {
 	// The requested ON/OFF state can only be 0xFF00 and 0x0000
 	if value != 0xFF00 && value != 0x0000 {
 		err = fmt.Errorf("modbus: state '%v' must be either 0xFF00 (ON) or 0x0000 (OFF)", value)
@@ -203,7 +252,7 @@ func (mb *client) WriteSingleCoil(address, value uint16) (results []byte, err er
 		FunctionCode: FuncCodeWriteSingleCoil,
 		Data:         mb.packager.DataBlock(address, value),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -238,11 +287,16 @@ func (mb *client) WriteSingleCoil(address, value uint16) (results []byte, err er
 //	Register address      : 2 bytes
 //	Register value        : 2 bytes
 func (mb *client) WriteSingleRegister(address, value uint16) (results []byte, err error) {
+	return mb.WriteSingleRegisterContext(context.Background(), address, value)
+}
+
+// WriteSingleRegisterContext is WriteSingleRegister bounded by ctx.
+func (mb *client) WriteSingleRegisterContext(ctx context.Context, address, value uint16) (results []byte, err error) {
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeWriteSingleRegister,
 		Data:         mb.packager.DataBlock(address, value),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -279,15 +333,20 @@ func (mb *client) WriteSingleRegister(address, value uint16) (results []byte, er
 //	Starting address      : 2 bytes
 //	Quantity of outputs   : 2 bytes
 func (mb *client) WriteMultipleCoils(address, quantity uint16, value []byte) (results []byte, err error) {
-	if quantity < 1 || quantity > 1968 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 1968)
+	return mb.WriteMultipleCoilsContext(context.Background(), address, quantity, value)
+}
+
+// WriteMultipleCoilsContext is WriteMultipleCoils bounded by ctx.
+func (mb *client) WriteMultipleCoilsContext(ctx context.Context, address, quantity uint16, value []byte) (results []byte, err error) {
+	if quantity < 1 || quantity > MaxWriteBits {
+		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, MaxWriteBits)
 		return
 	}
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeWriteMultipleCoils,
 		Data:         mb.packager.DataBlockSuffix(value, address, quantity),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -324,15 +383,20 @@ func (mb *client) WriteMultipleCoils(address, quantity uint16, value []byte) (re
 //	Starting address      : 2 bytes
 //	Quantity of registers : 2 bytes
 func (mb *client) WriteMultipleRegisters(address, quantity uint16, value []byte) (results []byte, err error) {
-	if quantity < 1 || quantity > 123 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 123)
+	return mb.WriteMultipleRegistersContext(context.Background(), address, quantity, value)
+}
+
+// WriteMultipleRegistersContext is WriteMultipleRegisters bounded by ctx.
+func (mb *client) WriteMultipleRegistersContext(ctx context.Context, address, quantity uint16, value []byte) (results []byte, err error) {
+	if quantity < 1 || quantity > MaxWriteRegisters {
+		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, MaxWriteRegisters)
 		return
 	}
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeWriteMultipleRegisters,
 		Data:         mb.packager.DataBlockSuffix(value, address, quantity),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -369,11 +433,16 @@ func (mb *client) WriteMultipleRegisters(address, quantity uint16, value []byte)
 //	AND-mask              : 2 bytes
 //	OR-mask               : 2 bytes
 func (mb *client) MaskWriteRegister(address, andMask, orMask uint16) (results []byte, err error) {
+	return mb.MaskWriteRegisterContext(context.Background(), address, andMask, orMask)
+}
+
+// MaskWriteRegisterContext is MaskWriteRegister bounded by ctx.
+func (mb *client) MaskWriteRegisterContext(ctx context.Context, address, andMask, orMask uint16) (results []byte, err error) {
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeMaskWriteRegister,
 		Data:         mb.packager.DataBlock(address, andMask, orMask),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -417,19 +486,24 @@ func (mb *client) MaskWriteRegister(address, andMask, orMask uint16) (results []
 //	Byte count            : 1 byte
 //	Read registers value  : Nx2 bytes
 func (mb *client) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) (results []byte, err error) {
-	if readQuantity < 1 || readQuantity > 125 {
-		err = fmt.Errorf("modbus: quantity to read '%v' must be between '%v' and '%v'", readQuantity, 1, 125)
+	return mb.ReadWriteMultipleRegistersContext(context.Background(), readAddress, readQuantity, writeAddress, writeQuantity, value)
+}
+
+// ReadWriteMultipleRegistersContext is ReadWriteMultipleRegisters bounded by ctx.
+func (mb *client) ReadWriteMultipleRegistersContext(ctx context.Context, readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) (results []byte, err error) {
+	if readQuantity < 1 || readQuantity > MaxReadRegisters {
+		err = fmt.Errorf("modbus: quantity to read '%v' must be between '%v' and '%v'", readQuantity, 1, MaxReadRegisters)
 		return
 	}
-	if writeQuantity < 1 || writeQuantity > 121 {
-		err = fmt.Errorf("modbus: quantity to write '%v' must be between '%v' and '%v'", writeQuantity, 1, 121)
+	if writeQuantity < 1 || writeQuantity > MaxReadWriteRegisters {
+		err = fmt.Errorf("modbus: quantity to write '%v' must be between '%v' and '%v'", writeQuantity, 1, MaxReadWriteRegisters)
 		return
 	}
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeReadWriteMultipleRegisters,
 		Data:         mb.packager.DataBlockSuffix(value, readAddress, readQuantity, writeAddress, writeQuantity),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -455,11 +529,16 @@ func (mb *client) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAdd
 //	FIFO count            : 2 bytes (<=31)
 //	FIFO value register   : Nx2 bytes
 func (mb *client) ReadFIFOQueue(address uint16) (results []byte, err error) {
+	return mb.ReadFIFOQueueContext(context.Background(), address)
+}
+
+// ReadFIFOQueueContext is ReadFIFOQueue bounded by ctx.
+func (mb *client) ReadFIFOQueueContext(ctx context.Context, address uint16) (results []byte, err error) {
 	request := ProtocolDataUnit{
 		FunctionCode: FuncCodeReadFIFOQueue,
 		Data:         mb.packager.DataBlock(address),
 	}
-	response, err := mb.send(&request)
+	response, err := mb.sendContext(ctx, &request)
 	if err != nil {
 		return
 	}
@@ -468,8 +547,8 @@ func (mb *client) ReadFIFOQueue(address uint16) (results []byte, err error) {
 		return
 	}
 	count := int(binary.BigEndian.Uint16(response.Data))
-	if count != (len(response.Data) - 1) {
-		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", len(response.Data)-1, count)
+	if count != (len(response.Data) - 2) {
+		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", len(response.Data)-2, count)
 		return
 	}
 	count = int(binary.BigEndian.Uint16(response.Data[2:]))
@@ -481,20 +560,652 @@ func (mb *client) ReadFIFOQueue(address uint16) (results []byte, err error) {
 	return
 }
 
+// ReadDeviceIdentification Request:
+//
+//	Function code         : 1 byte (0x2B)
+//	MEI type              : 1 byte (0x0E)
+//	Read device id code   : 1 byte
+//	Object id             : 1 byte
+//
+// Response:
+//
+//	Function code         : 1 byte (0x2B)
+//	MEI type              : 1 byte (0x0E)
+//	Read device id code   : 1 byte
+//	Conformity level      : 1 byte
+//	More follows          : 1 byte (0x00 or 0xFF)
+//	Next object id        : 1 byte
+//	Number of objects     : 1 byte
+//	Object id             : 1 byte
+//	Object length         : 1 byte
+//	Object value          : N bytes
+//	... repeated for each object
+func (mb *client) ReadDeviceIdentification(readDeviceIdCode, objectId byte) (results map[byte][]byte, err error) {
+	return mb.ReadDeviceIdentificationContext(context.Background(), readDeviceIdCode, objectId)
+}
+
+// ReadDeviceIdentificationContext is ReadDeviceIdentification bounded by ctx.
+func (mb *client) ReadDeviceIdentificationContext(ctx context.Context, readDeviceIdCode, objectId byte) (results map[byte][]byte, err error) {
+	if readDeviceIdCode < ReadDeviceIdCodeBasic || readDeviceIdCode > ReadDeviceIdCodeSpecific {
+		err = fmt.Errorf("modbus: read device id code '%v' must be between '%v' and '%v'", readDeviceIdCode, ReadDeviceIdCodeBasic, ReadDeviceIdCodeSpecific)
+		return
+	}
+	results = make(map[byte][]byte)
+	for {
+		request := ProtocolDataUnit{
+			FunctionCode: FuncCodeEncapsulatedInterfaceTransport,
+			Data:         []byte{MEITypeReadDeviceIdentification, readDeviceIdCode, objectId},
+		}
+		var response *ProtocolDataUnit
+		response, err = mb.sendContext(ctx, &request)
+		if err != nil {
+			return
+		}
+		if len(response.Data) < 6 {
+			err = fmt.Errorf("modbus: response data size '%v' is less than expected '%v'", len(response.Data), 6)
+			return
+		}
+		if response.Data[0] != MEITypeReadDeviceIdentification {
+			err = fmt.Errorf("modbus: response MEI type '%v' does not match request '%v'", response.Data[0], MEITypeReadDeviceIdentification)
+			return
+		}
+		moreFollows, nextObjectId := response.Data[3], response.Data[4]
+		count := int(response.Data[5])
+		data := response.Data[6:]
+		for i := 0; i < count; i++ {
+			if len(data) < 2 || len(data) < 2+int(data[1]) {
+				err = fmt.Errorf("modbus: response object '%v' of '%v' is truncated", i+1, count)
+				return
+			}
+			length := int(data[1])
+			results[data[0]] = data[2 : 2+length]
+			data = data[2+length:]
+		}
+		if moreFollows != 0xFF {
+			return
+		}
+		// The remaining objects are fetched by continuing from the next object id
+		if nextObjectId <= objectId {
+			err = fmt.Errorf("modbus: response next object id '%v' does not follow request '%v'", nextObjectId, objectId)
+			return
+		}
+		objectId = nextObjectId
+	}
+}
+
+// ReadExceptionStatus Request:
+//
+//	Function code         : 1 byte (0x07)
+//
+// Response:
+//
+//	Function code         : 1 byte (0x07)
+//	Output data           : 1 byte
+func (mb *client) ReadExceptionStatus() (status byte, err error) {
+	return mb.ReadExceptionStatusContext(context.Background())
+}
+
+// ReadExceptionStatusContext is ReadExceptionStatus bounded by ctx.
+func (mb *client) ReadExceptionStatusContext(ctx context.Context) (status byte, err error) {
+	request := ProtocolDataUnit{
+		FunctionCode: FuncCodeReadExceptionStatus,
+	}
+	response, err := mb.sendContext(ctx, &request)
+	if err != nil {
+		return
+	}
+	// Fixed response length
+	if len(response.Data) != 1 {
+		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 1)
+		return
+	}
+	status = response.Data[0]
+	return
+}
+
+// Diagnostics Request:
+//
+//	Function code         : 1 byte (0x08)
+//	Sub-function          : 2 bytes
+//	Data                  : N bytes
+//
+// Response:
+//
+//	Function code         : 1 byte (0x08)
+//	Sub-function          : 2 bytes
+//	Data                  : N bytes
+func (mb *client) Diagnostics(subFunction uint16, data []byte) (results []byte, err error) {
+	return mb.DiagnosticsContext(context.Background(), subFunction, data)
+}
+
+// DiagnosticsContext is Diagnostics bounded by ctx.
+func (mb *client) DiagnosticsContext(ctx context.Context, subFunction uint16, data []byte) (results []byte, err error) {
+	request := ProtocolDataUnit{
+		FunctionCode: FuncCodeDiagnostics,
+		Data:         append(mb.packager.DataBlock(subFunction), data...),
+	}
+	response, err := mb.sendContext(ctx, &request)
+	if err != nil {
+		return
+	}
+	if len(response.Data) < 2 {
+		err = fmt.Errorf("modbus: response data size '%v' is less than expected '%v'", len(response.Data), 2)
+		return
+	}
+	respValue := binary.BigEndian.Uint16(response.Data)
+	if subFunction != respValue {
+		err = fmt.Errorf("modbus: response sub-function '%v' does not match request '%v'", respValue, subFunction)
+		return
+	}
+	results = response.Data[2:]
+	return
+}
+
+// ReturnQueryData asks the remote device to echo data back.
+func (mb *client) ReturnQueryData(data []byte) (results []byte, err error) {
+	return mb.ReturnQueryDataContext(context.Background(), data)
+}
+
+// ReturnQueryDataContext is ReturnQueryData bounded by ctx.
+func (mb *client) ReturnQueryDataContext(ctx context.Context, data []byte) (results []byte, err error) {
+	results, err = mb.DiagnosticsContext(ctx, DiagSubFuncReturnQueryData, data)
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(data, results) {
+		err = fmt.Errorf("modbus: response data '% x' does not match request '% x'", results, data)
+		return
+	}
+	return
+}
+
+// RestartCommunications restarts the serial line port of the remote device
+// and brings it out of listen only mode, optionally clearing its
+// communications event log.
+func (mb *client) RestartCommunications(clearEventLog bool) (err error) {
+	return mb.RestartCommunicationsContext(context.Background(), clearEventLog)
+}
+
+// RestartCommunicationsContext is RestartCommunications bounded by ctx.
+func (mb *client) RestartCommunicationsContext(ctx context.Context, clearEventLog bool) (err error) {
+	var value uint16
+	if clearEventLog {
+		value = 0xFF00
+	}
+	return mb.diagnosticsEcho(ctx, DiagSubFuncRestartCommunicationsOption, value)
+}
+
+// ClearCounters clears all counters and the diagnostic register.
+func (mb *client) ClearCounters() (err error) {
+	return mb.ClearCountersContext(context.Background())
+}
+
+// ClearCountersContext is ClearCounters bounded by ctx.
+func (mb *client) ClearCountersContext(ctx context.Context) (err error) {
+	return mb.diagnosticsEcho(ctx, DiagSubFuncClearCountersAndDiagnosticRegister, 0)
+}
+
+// ForceListenOnlyMode isolates the remote device from the other devices on
+// the bus. The device does not answer this request.
+func (mb *client) ForceListenOnlyMode() (err error) {
+	return mb.ForceListenOnlyModeContext(context.Background())
+}
+
+// ForceListenOnlyModeContext is ForceListenOnlyMode bounded by ctx.
+func (mb *client) ForceListenOnlyModeContext(ctx context.Context) (err error) {
+	return mb.diagnosticsEcho(ctx, DiagSubFuncForceListenOnlyMode, 0)
+}
+
+// ReadDiagnosticCounter returns the diagnostic register or one of the
+// counters selected by subFunction, e.g. DiagSubFuncReturnBusMessageCount
+// or DiagSubFuncReturnBusCommunicationErrorCount (CRC errors).
+func (mb *client) ReadDiagnosticCounter(subFunction uint16) (count uint16, err error) {
+	return mb.ReadDiagnosticCounterContext(context.Background(), subFunction)
+}
+
+// ReadDiagnosticCounterContext is ReadDiagnosticCounter bounded by ctx.
+func (mb *client) ReadDiagnosticCounterContext(ctx context.Context, subFunction uint16) (count uint16, err error) {
+	if subFunction != DiagSubFuncReturnDiagnosticRegister &&
+		(subFunction < DiagSubFuncReturnBusMessageCount || subFunction > DiagSubFuncReturnBusCharacterOverrunCount) {
+		err = fmt.Errorf("modbus: sub-function '%v' does not return a counter", subFunction)
+		return
+	}
+	results, err := mb.DiagnosticsContext(ctx, subFunction, mb.packager.DataBlock(0))
+	if err != nil {
+		return
+	}
+	// Fixed response length
+	if len(results) != 2 {
+		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(results)+2, 4)
+		return
+	}
+	count = binary.BigEndian.Uint16(results)
+	return
+}
+
+// diagnosticsEcho performs a sub-function whose response echoes the
+// request data.
+func (mb *client) diagnosticsEcho(ctx context.Context, subFunction, value uint16) (err error) {
+	results, err := mb.DiagnosticsContext(ctx, subFunction, mb.packager.DataBlock(value))
+	if err != nil {
+		return
+	}
+	if len(results) != 2 {
+		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(results)+2, 4)
+		return
+	}
+	respValue := binary.BigEndian.Uint16(results)
+	if value != respValue {
+		err = fmt.Errorf("modbus: response value '%v' does not match request '%v'", respValue, value)
+		return
+	}
+	return
+}
+
+// GetCommEventCounter Request:
+//
+//	Function code         : 1 byte (0x0B)
+//
+// Response:
+//
+//	Function code         : 1 byte (0x0B)
+//	Status                : 2 bytes
+//	Event count           : 2 bytes
+func (mb *client) GetCommEventCounter() (result *CommEventCounter, err error) {
+	return mb.GetCommEventCounterContext(context.Background())
+}
+
+// GetCommEventCounterContext is GetCommEventCounter bounded by ctx.
+func (mb *client) GetCommEventCounterContext(ctx context.Context) (result *CommEventCounter, err error) {
+	request := ProtocolDataUnit{
+		FunctionCode: FuncCodeGetCommEventCounter,
+	}
+	response, err := mb.sendContext(ctx, &request)
+	if err != nil {
+		return
+	}
+	// Fixed response length
+	if len(response.Data) != 4 {
+		err = fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 4)
+		return
+	}
+	result = &CommEventCounter{
+		Status:     binary.BigEndian.Uint16(response.Data),
+		EventCount: binary.BigEndian.Uint16(response.Data[2:]),
+	}
+	return
+}
+
+// GetCommEventLog Request:
+//
+//	Function code         : 1 byte (0x0C)
+//
+// Response:
+//
+//	Function code         : 1 byte (0x0C)
+//	Byte count            : 1 byte
+//	Status                : 2 bytes
+//	Event count           : 2 bytes
+//	Message count         : 2 bytes
+//	Events                : (N-6) bytes (<=64)
+func (mb *client) GetCommEventLog() (result *CommEventLog, err error) {
+	return mb.GetCommEventLogContext(context.Background())
+}
+
+// GetCommEventLogContext is GetCommEventLog bounded by ctx.
+func (mb *client) GetCommEventLogContext(ctx context.Context) (result *CommEventLog, err error) {
+	request := ProtocolDataUnit{
+		FunctionCode: FuncCodeGetCommEventLog,
+	}
+	response, err := mb.sendContext(ctx, &request)
+	if err != nil {
+		return
+	}
+	if len(response.Data) < 7 {
+		err = fmt.Errorf("modbus: response data size '%v' is less than expected '%v'", len(response.Data), 7)
+		return
+	}
+	count := int(response.Data[0])
+	length := len(response.Data) - 1
+	if count != length {
+		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", length, count)
+		return
+	}
+	result = &CommEventLog{
+		Status:       binary.BigEndian.Uint16(response.Data[1:]),
+		EventCount:   binary.BigEndian.Uint16(response.Data[3:]),
+		MessageCount: binary.BigEndian.Uint16(response.Data[5:]),
+		Events:       response.Data[7:],
+	}
+	return
+}
+
+// ReportServerId Request:
+//
+//	Function code         : 1 byte (0x11)
+//
+// Response:
+//
+//	Function code         : 1 byte (0x11)
+//	Byte count            : 1 byte
+//	Server id             : device specific
+//	Run indicator status  : 1 byte (0x00 or 0xFF)
+//	Additional data       : device specific
+func (mb *client) ReportServerId() (result *ServerIdReport, err error) {
+	return mb.ReportServerIdContext(context.Background())
+}
+
+// ReportServerIdContext is ReportServerId bounded by ctx.
+func (mb *client) ReportServerIdContext(ctx context.Context) (result *ServerIdReport, err error) {
+	request := ProtocolDataUnit{
+		FunctionCode: FuncCodeReportServerId,
+	}
+	response, err := mb.sendContext(ctx, &request)
+	if err != nil {
+		return
+	}
+	if len(response.Data) < 3 {
+		err = fmt.Errorf("modbus: response data size '%v' is less than expected '%v'", len(response.Data), 3)
+		return
+	}
+	count := int(response.Data[0])
+	length := len(response.Data) - 1
+	if count != length {
+		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", length, count)
+		return
+	}
+	result = &ServerIdReport{
+		ServerId:           response.Data[1],
+		RunIndicatorStatus: response.Data[2] == 0xFF,
+		AdditionalData:     response.Data[3:],
+		Data:               response.Data[1:],
+	}
+	return
+}
+
+// ReadFileRecord Request:
+//
+//	Function code         : 1 byte (0x14)
+//	Byte count            : 1 byte
+//	Reference type        : 1 byte (0x06)
+//	File number           : 2 bytes
+//	Record number         : 2 bytes
+//	Record length         : 2 bytes
+//	... repeated for each sub-request
+//
+// Response:
+//
+//	Function code         : 1 byte (0x14)
+//	Response data length  : 1 byte
+//	File response length  : 1 byte
+//	Reference type        : 1 byte (0x06)
+//	Record data           : Nx2 bytes
+//	... repeated for each sub-request
+func (mb *client) ReadFileRecord(records []FileRecord) (results [][]byte, err error) {
+	return mb.ReadFileRecordContext(context.Background(), records)
+}
+
+// ReadFileRecordContext is ReadFileRecord bounded by ctx.
+func (mb *client) ReadFileRecordContext(ctx context.Context, records []FileRecord) (results [][]byte, err error) {
+	if len(records) < 1 {
+		err = fmt.Errorf("modbus: number of file sub-requests '%v' must not be zero", len(records))
+		return
+	}
+	data := []byte{byte(7 * len(records))}
+	// Response data length, following the byte count
+	expected := 0
+	for _, record := range records {
+		if err = checkFileRecord(&record); err != nil {
+			return
+		}
+		if record.RecordLength < 1 {
+			err = fmt.Errorf("modbus: record length '%v' must not be zero", record.RecordLength)
+			return
+		}
+		data = append(data, fileRecordReferenceType)
+		data = append(data, mb.packager.DataBlock(record.FileNumber, record.RecordNumber, record.RecordLength)...)
+		expected += 2 + 2*int(record.RecordLength)
+	}
+	if len(data)-1 > fileReadMaxLength || expected > fileReadMaxLength {
+		err = fmt.Errorf("modbus: file sub-requests size '%v' must not be bigger than '%v'", maxInt(len(data)-1, expected), fileReadMaxLength)
+		return
+	}
+	request := ProtocolDataUnit{
+		FunctionCode: FuncCodeReadFileRecord,
+		Data:         data,
+	}
+	response, err := mb.sendContext(ctx, &request)
+	if err != nil {
+		return
+	}
+	count := int(response.Data[0])
+	length := len(response.Data) - 1
+	if count != length {
+		err = fmt.Errorf("modbus: response data size '%v' does not match count '%v'", length, count)
+		return
+	}
+	data = response.Data[1:]
+	results = make([][]byte, len(records))
+	for i, record := range records {
+		if len(data) < 2 {
+			err = fmt.Errorf("modbus: response sub-response '%v' of '%v' is missing", i+1, len(records))
+			return
+		}
+		length = int(data[0])
+		if length != 1+2*int(record.RecordLength) || len(data) < 1+length {
+			err = fmt.Errorf("modbus: response file length '%v' does not match record length '%v'", length, record.RecordLength)
+			return
+		}
+		if data[1] != fileRecordReferenceType {
+			err = fmt.Errorf("modbus: response reference type '%v' does not match '%v'", data[1], fileRecordReferenceType)
+			return
+		}
+		results[i] = data[2 : 1+length]
+		data = data[1+length:]
+	}
+	return
+}
+
+// WriteFileRecord Request:
+//
+//	Function code         : 1 byte (0x15)
+//	Request data length   : 1 byte
+//	Reference type        : 1 byte (0x06)
+//	File number           : 2 bytes
+//	Record number         : 2 bytes
+//	Record length         : 2 bytes
+//	Record data           : Nx2 bytes
+//	... repeated for each sub-request
+//
+// Response:
+//
+//	Echo of the request
+func (mb *client) WriteFileRecord(records []FileRecord) (err error) {
+	return mb.WriteFileRecordContext(context.Background(), records)
+}
+
+// WriteFileRecordContext is WriteFileRecord bounded by ctx.
+func (mb *client) WriteFileRecordContext(ctx context.Context, records []FileRecord) (err error) {
+	if len(records) < 1 {
+		err = fmt.Errorf("modbus: number of file sub-requests '%v' must not be zero", len(records))
+		return
+	}
+	data := []byte{0}
+	for _, record := range records {
+		if err = checkFileRecord(&record); err != nil {
+			return
+		}
+		if len(record.Data) < 2 || len(record.Data)%2 != 0 {
+			err = fmt.Errorf("modbus: record data size '%v' must be a non-zero multiple of '%v'", len(record.Data), 2)
+			return
+		}
+		data = append(data, fileRecordReferenceType)
+		data = append(data, mb.packager.DataBlock(record.FileNumber, record.RecordNumber, uint16(len(record.Data)/2))...)
+		data = append(data, record.Data...)
+	}
+	if len(data) > fileRecordMaxLength {
+		err = fmt.Errorf("modbus: file sub-requests size '%v' must not be bigger than '%v'", len(data), fileRecordMaxLength)
+		return
+	}
+	data[0] = byte(len(data) - 1)
+	request := ProtocolDataUnit{
+		FunctionCode: FuncCodeWriteFileRecord,
+		Data:         data,
+	}
+	response, err := mb.sendContext(ctx, &request)
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(response.Data, request.Data) {
+		err = fmt.Errorf("modbus: response data '% x' does not match request '% x'", response.Data, request.Data)
+		return
+	}
+	return
+}
+
+// checkFileRecord validates the file and record numbers of a sub-request.
+func checkFileRecord(record *FileRecord) (err error) {
+	if record.FileNumber < 1 {
+		err = fmt.Errorf("modbus: file number '%v' must not be zero", record.FileNumber)
+		return
+	}
+	if record.RecordNumber > fileRecordMaxNumber {
+		err = fmt.Errorf("modbus: record number '%v' must not be bigger than '%v'", record.RecordNumber, fileRecordMaxNumber)
+		return
+	}
+	return
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// SendPDU Request:
+//
+//	Function code         : 1 byte
+//	Data                  : N bytes
+//
+// Response:
+//
+//	Function code         : 1 byte
+//	Data                  : N bytes
+func (mb *client) SendPDU(functionCode byte, data []byte) (results []byte, err error) {
+	return mb.SendPDUContext(context.Background(), functionCode, data)
+}
+
+// SendPDUContext is SendPDU bounded by ctx.
+func (mb *client) SendPDUContext(ctx context.Context, functionCode byte, data []byte) (results []byte, err error) {
+	// Codes with the highest bit set are reserved for exception responses
+	if functionCode < 1 || functionCode > 127 {
+		err = fmt.Errorf("modbus: function code '%v' must be between '%v' and '%v'", functionCode, 1, 127)
+		return
+	}
+	request := ProtocolDataUnit{
+		FunctionCode: functionCode,
+		Data:         data,
+	}
+	response, err := mb.sendContext(ctx, &request)
+	if err != nil {
+		return
+	}
+	results = response.Data
+	return
+}
+
 // Helpers
 
-// send sends request and checks possible exception in the response.
-func (mb *client) send(request *ProtocolDataUnit) (response *ProtocolDataUnit, err error) {
-	aduRequest, err := mb.packager.Encode(request)
+// sendContext sends request and checks possible exception in the response,
+// sending it again as allowed by the retry policy. The transaction is
+// aborted when ctx is done if the transporter supports it.
+func (mb *client) sendContext(ctx context.Context, request *ProtocolDataUnit) (response *ProtocolDataUnit, err error) {
+	broadcast := mb.broadcast()
+	if broadcast && !broadcastable(request.FunctionCode) {
+		err = fmt.Errorf("modbus: function '%v' must not be broadcast", request.FunctionCode)
+		return
+	}
+	breaker := mb.breaker
+	slaveId, ok := mb.slaveId()
+	if !ok || broadcast {
+		// Broadcast requests get no response to judge the slaves
+		breaker = nil
+	}
+	for attempt := 1; ; attempt++ {
+		if breaker != nil {
+			if err = breaker.allow(slaveId); err != nil {
+				return
+			}
+			response, err = mb.sendOnce(ctx, request)
+			breaker.record(slaveId, err)
+		} else {
+			response, err = mb.sendOnce(ctx, request)
+		}
+		if err == nil || mb.retry == nil || !mb.retry.shouldRetry(request, attempt, err) {
+			return
+		}
+		if sleepContext(ctx, mb.retry.backoff(attempt)) != nil {
+			return
+		}
+	}
+}
+
+// WithSlave returns a view of the client sending requests to slaveId.
+func (mb *client) WithSlave(slaveId byte) ClientContext {
+	view := *mb
+	view.slave = slaveId
+	view.hasSlave = true
+	return &view
+}
+
+// slaveId returns the slave id requests are sent to, if the packager
+// addresses requests to a slave id.
+func (mb *client) slaveId() (slaveId byte, ok bool) {
+	if mb.hasSlave {
+		return mb.slave, true
+	}
+	if packager, ok := mb.packager.(slavePackager); ok {
+		return packager.slaveId(), true
+	}
+	return
+}
+
+// encode encodes request for the slave of the view, or the slave of the
+// packager.
+func (mb *client) encode(request *ProtocolDataUnit) (aduRequest []byte, err error) {
+	if !mb.hasSlave {
+		return mb.packager.Encode(request)
+	}
+	packager, ok := mb.packager.(slavePackager)
+	if !ok {
+		err = fmt.Errorf("modbus: packager '%T' does not address slave ids", mb.packager)
+		return
+	}
+	return packager.encodeSlave(request, mb.slave)
+}
+
+// sendOnce sends request in a single attempt.
+func (mb *client) sendOnce(ctx context.Context, request *ProtocolDataUnit) (response *ProtocolDataUnit, err error) {
+	aduRequest, err := mb.encode(request)
 	if err != nil {
 		return
 	}
-	aduResponse, err := mb.transporter.Send(aduRequest)
+	var aduResponse []byte
+	if transporter, ok := mb.transporter.(TransporterContext); ok {
+		aduResponse, err = transporter.SendContext(ctx, aduRequest)
+	} else if err = ctx.Err(); err == nil {
+		aduResponse, err = mb.transporter.Send(aduRequest)
+	}
 	if err != nil {
 		return
 	}
+	if aduResponse == nil && !mb.responseExpected(request) {
+		response = unansweredResponse(request)
+		return
+	}
 	if err = mb.packager.Verify(aduRequest, aduResponse); err != nil {
-		if _, ok := mb.transporter.(*RTUClientHandler); ok {
+		if transporter, ok := mb.transporter.(mismatchTolerant); ok && transporter.toleratesMismatch() {
 			return
 		}
 		mb.transporter.Close()
@@ -517,6 +1228,12 @@ func (mb *client) send(request *ProtocolDataUnit) (response *ProtocolDataUnit, e
 	return
 }
 
+// mismatchTolerant is implemented by transporters whose connection remains
+// usable after a response fails verification, which otherwise closes it.
+type mismatchTolerant interface {
+	toleratesMismatch() bool
+}
+
 func responseError(response *ProtocolDataUnit) error {
 	mbError := &ModbusError{FunctionCode: response.FunctionCode}
 	if response.Data != nil && len(response.Data) > 0 {
@@ -524,3 +1241,52 @@ func responseError(response *ProtocolDataUnit) error {
 	}
 	return mbError
 }
+
+// broadcast reports whether requests are broadcast to all slaves.
+func (mb *client) broadcast() bool {
+	slaveId, ok := mb.slaveId()
+	if !ok || slaveId != 0 {
+		return false
+	}
+	packager, ok := mb.packager.(slavePackager)
+	return ok && packager.broadcasts()
+}
+
+// broadcastable reports whether a request of the function may be broadcast,
+// which is the case of writes.
+func broadcastable(functionCode byte) bool {
+	switch functionCode {
+	case FuncCodeWriteSingleCoil,
+		FuncCodeWriteMultipleCoils,
+		FuncCodeWriteSingleRegister,
+		FuncCodeWriteMultipleRegisters,
+		FuncCodeMaskWriteRegister,
+		FuncCodeWriteFileRecord:
+		return true
+	}
+	return false
+}
+
+// unansweredResponse returns the response of a successful request which is
+// not answered, as if the remote device had answered it.
+func unansweredResponse(request *ProtocolDataUnit) *ProtocolDataUnit {
+	switch request.FunctionCode {
+	case FuncCodeWriteMultipleCoils, FuncCodeWriteMultipleRegisters:
+		// Starting address and quantity
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: request.Data[:4]}
+	}
+	// The other requests are echoed
+	return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: request.Data}
+}
+
+// responseExpected reports whether the remote device answers request.
+func (mb *client) responseExpected(request *ProtocolDataUnit) bool {
+	if mb.broadcast() {
+		return false
+	}
+	if request.FunctionCode == FuncCodeDiagnostics && len(request.Data) >= 2 &&
+		binary.BigEndian.Uint16(request.Data) == DiagSubFuncForceListenOnlyMode {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,346 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// ByteOrder is the order in which the bytes of a value spanning one or more
+// registers are transferred, named after the bytes of 0xAABBCCDD. Longer
+// values extend the same rule to all their words.
+type ByteOrder byte
+
+const (
+	// ABCD is big endian, the order of the Modbus specification.
+	ABCD ByteOrder = iota
+	// CDAB sends the least significant word first, each word big endian.
+	CDAB
+	// BADC sends the most significant word first, each word little endian.
+	BADC
+	// DCBA is little endian.
+	DCBA
+)
+
+func (order ByteOrder) String() string {
+	switch order {
+	case ABCD:
+		return "ABCD"
+	case CDAB:
+		return "CDAB"
+	case BADC:
+		return "BADC"
+	case DCBA:
+		return "DCBA"
+	}
+	return fmt.Sprintf("ByteOrder(%d)", byte(order))
+}
+
+// Uint16 decodes a register. Only the byte swapping orders apply.
+func (order ByteOrder) Uint16(b []byte) uint16 {
+	var v [2]byte
+	copy(v[:], b[:2])
+	order.reorder(v[:])
+	return binary.BigEndian.Uint16(v[:])
+}
+
+// PutUint16 encodes v in a register.
+func (order ByteOrder) PutUint16(b []byte, v uint16) {
+	binary.BigEndian.PutUint16(b, v)
+	order.reorder(b[:2])
+}
+
+// Uint32 decodes two registers.
+func (order ByteOrder) Uint32(b []byte) uint32 {
+	var v [4]byte
+	copy(v[:], b[:4])
+	order.reorder(v[:])
+	return binary.BigEndian.Uint32(v[:])
+}
+
+// PutUint32 encodes v in two registers.
+func (order ByteOrder) PutUint32(b []byte, v uint32) {
+	binary.BigEndian.PutUint32(b, v)
+	order.reorder(b[:4])
+}
+
+// Uint64 decodes four registers.
+func (order ByteOrder) Uint64(b []byte) uint64 {
+	var v [8]byte
+	copy(v[:], b[:8])
+	order.reorder(v[:])
+	return binary.BigEndian.Uint64(v[:])
+}
+
+// PutUint64 encodes v in four registers.
+func (order ByteOrder) PutUint64(b []byte, v uint64) {
+	binary.BigEndian.PutUint64(b, v)
+	order.reorder(b[:8])
+}
+
+// reorder converts in place between the big endian bytes of a value and
+// the bytes of its registers. Swapping words and swapping bytes are both
+// their own inverse, so the conversion works either way.
+func (order ByteOrder) reorder(b []byte) {
+	if order == CDAB || order == DCBA {
+		for i, j := 0, len(b)-2; i < j; i, j = i+2, j-2 {
+			b[i], b[i+1], b[j], b[j+1] = b[j], b[j+1], b[i], b[i+1]
+		}
+	}
+	if order == BADC || order == DCBA {
+		for i := 0; i+1 < len(b); i += 2 {
+			b[i], b[i+1] = b[i+1], b[i]
+		}
+	}
+}
+
+// TypedClient reads and writes 32 and 64-bit integers and floats stored in
+// consecutive registers. Each value occupies two or four registers, the
+// quantity of a request is computed from the number of values.
+type TypedClient struct {
+	Client ClientContext
+}
+
+// NewTypedClient allocates a TypedClient on top of client.
+func NewTypedClient(client ClientContext) *TypedClient {
+	return &TypedClient{Client: client}
+}
+
+// ReadInt32s reads count int32 values from holding registers.
+func (tc *TypedClient) ReadInt32s(address, count uint16, order ByteOrder) (values []int32, err error) {
+	return tc.ReadInt32sContext(context.Background(), address, count, order)
+}
+
+// ReadInt32sContext is ReadInt32s bounded by ctx.
+func (tc *TypedClient) ReadInt32sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []int32, err error) {
+	return readValues(ctx, tc.Client.ReadHoldingRegistersContext, address, count, 4, func(b []byte) int32 {
+		return int32(order.Uint32(b))
+	})
+}
+
+// ReadUint32s reads count uint32 values from holding registers.
+func (tc *TypedClient) ReadUint32s(address, count uint16, order ByteOrder) (values []uint32, err error) {
+	return tc.ReadUint32sContext(context.Background(), address, count, order)
+}
+
+// ReadUint32sContext is ReadUint32s bounded by ctx.
+func (tc *TypedClient) ReadUint32sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []uint32, err error) {
+	return readValues(ctx, tc.Client.ReadHoldingRegistersContext, address, count, 4, order.Uint32)
+}
+
+// ReadFloat32s reads count float32 values from holding registers.
+func (tc *TypedClient) ReadFloat32s(address, count uint16, order ByteOrder) (values []float32, err error) {
+	return tc.ReadFloat32sContext(context.Background(), address, count, order)
+}
+
+// ReadFloat32sContext is ReadFloat32s bounded by ctx.
+func (tc *TypedClient) ReadFloat32sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []float32, err error) {
+	return readValues(ctx, tc.Client.ReadHoldingRegistersContext, address, count, 4, func(b []byte) float32 {
+		return math.Float32frombits(order.Uint32(b))
+	})
+}
+
+// ReadInt64s reads count int64 values from holding registers.
+func (tc *TypedClient) ReadInt64s(address, count uint16, order ByteOrder) (values []int64, err error) {
+	return tc.ReadInt64sContext(context.Background(), address, count, order)
+}
+
+// ReadInt64sContext is ReadInt64s bounded by ctx.
+func (tc *TypedClient) ReadInt64sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []int64, err error) {
+	return readValues(ctx, tc.Client.ReadHoldingRegistersContext, address, count, 8, func(b []byte) int64 {
+		return int64(order.Uint64(b))
+	})
+}
+
+// ReadUint64s reads count uint64 values from holding registers.
+func (tc *TypedClient) ReadUint64s(address, count uint16, order ByteOrder) (values []uint64, err error) {
+	return tc.ReadUint64sContext(context.Background(), address, count, order)
+}
+
+// ReadUint64sContext is ReadUint64s bounded by ctx.
+func (tc *TypedClient) ReadUint64sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []uint64, err error) {
+	return readValues(ctx, tc.Client.ReadHoldingRegistersContext, address, count, 8, order.Uint64)
+}
+
+// ReadFloat64s reads count float64 values from holding registers.
+func (tc *TypedClient) ReadFloat64s(address, count uint16, order ByteOrder) (values []float64, err error) {
+	return tc.ReadFloat64sContext(context.Background(), address, count, order)
+}
+
+// ReadFloat64sContext is ReadFloat64s bounded by ctx.
+func (tc *TypedClient) ReadFloat64sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []float64, err error) {
+	return readValues(ctx, tc.Client.ReadHoldingRegistersContext, address, count, 8, func(b []byte) float64 {
+		return math.Float64frombits(order.Uint64(b))
+	})
+}
+
+// ReadInputInt32s reads count int32 values from input registers.
+func (tc *TypedClient) ReadInputInt32s(address, count uint16, order ByteOrder) (values []int32, err error) {
+	return tc.ReadInputInt32sContext(context.Background(), address, count, order)
+}
+
+// ReadInputInt32sContext is ReadInputInt32s bounded by ctx.
+func (tc *TypedClient) ReadInputInt32sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []int32, err error) {
+	return readValues(ctx, tc.Client.ReadInputRegistersContext, address, count, 4, func(b []byte) int32 {
+		return int32(order.Uint32(b))
+	})
+}
+
+// ReadInputUint32s reads count uint32 values from input registers.
+func (tc *TypedClient) ReadInputUint32s(address, count uint16, order ByteOrder) (values []uint32, err error) {
+	return tc.ReadInputUint32sContext(context.Background(), address, count, order)
+}
+
+// ReadInputUint32sContext is ReadInputUint32s bounded by ctx.
+func (tc *TypedClient) ReadInputUint32sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []uint32, err error) {
+	return readValues(ctx, tc.Client.ReadInputRegistersContext, address, count, 4, order.Uint32)
+}
+
+// ReadInputFloat32s reads count float32 values from input registers.
+func (tc *TypedClient) ReadInputFloat32s(address, count uint16, order ByteOrder) (values []float32, err error) {
+	return tc.ReadInputFloat32sContext(context.Background(), address, count, order)
+}
+
+// ReadInputFloat32sContext is ReadInputFloat32s bounded by ctx.
+func (tc *TypedClient) ReadInputFloat32sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []float32, err error) {
+	return readValues(ctx, tc.Client.ReadInputRegistersContext, address, count, 4, func(b []byte) float32 {
+		return math.Float32frombits(order.Uint32(b))
+	})
+}
+
+// ReadInputInt64s reads count int64 values from input registers.
+func (tc *TypedClient) ReadInputInt64s(address, count uint16, order ByteOrder) (values []int64, err error) {
+	return tc.ReadInputInt64sContext(context.Background(), address, count, order)
+}
+
+// ReadInputInt64sContext is ReadInputInt64s bounded by ctx.
+func (tc *TypedClient) ReadInputInt64sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []int64, err error) {
+	return readValues(ctx, tc.Client.ReadInputRegistersContext, address, count, 8, func(b []byte) int64 {
+		return int64(order.Uint64(b))
+	})
+}
+
+// ReadInputUint64s reads count uint64 values from input registers.
+func (tc *TypedClient) ReadInputUint64s(address, count uint16, order ByteOrder) (values []uint64, err error) {
+	return tc.ReadInputUint64sContext(context.Background(), address, count, order)
+}
+
+// ReadInputUint64sContext is ReadInputUint64s bounded by ctx.
+func (tc *TypedClient) ReadInputUint64sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []uint64, err error) {
+	return readValues(ctx, tc.Client.ReadInputRegistersContext, address, count, 8, order.Uint64)
+}
+
+// ReadInputFloat64s reads count float64 values from input registers.
+func (tc *TypedClient) ReadInputFloat64s(address, count uint16, order ByteOrder) (values []float64, err error) {
+	return tc.ReadInputFloat64sContext(context.Background(), address, count, order)
+}
+
+// ReadInputFloat64sContext is ReadInputFloat64s bounded by ctx.
+func (tc *TypedClient) ReadInputFloat64sContext(ctx context.Context, address, count uint16, order ByteOrder) (values []float64, err error) {
+	return readValues(ctx, tc.Client.ReadInputRegistersContext, address, count, 8, func(b []byte) float64 {
+		return math.Float64frombits(order.Uint64(b))
+	})
+}
+
+// WriteInt32s writes int32 values to holding registers.
+func (tc *TypedClient) WriteInt32s(address uint16, values []int32, order ByteOrder) (err error) {
+	return tc.WriteInt32sContext(context.Background(), address, values, order)
+}
+
+// WriteInt32sContext is WriteInt32s bounded by ctx.
+func (tc *TypedClient) WriteInt32sContext(ctx context.Context, address uint16, values []int32, order ByteOrder) (err error) {
+	return writeValues(ctx, tc.Client, address, values, 4, func(b []byte, v int32) {
+		order.PutUint32(b, uint32(v))
+	})
+}
+
+// WriteUint32s writes uint32 values to holding registers.
+func (tc *TypedClient) WriteUint32s(address uint16, values []uint32, order ByteOrder) (err error) {
+	return tc.WriteUint32sContext(context.Background(), address, values, order)
+}
+
+// WriteUint32sContext is WriteUint32s bounded by ctx.
+func (tc *TypedClient) WriteUint32sContext(ctx context.Context, address uint16, values []uint32, order ByteOrder) (err error) {
+	return writeValues(ctx, tc.Client, address, values, 4, order.PutUint32)
+}
+
+// WriteFloat32s writes float32 values to holding registers.
+func (tc *TypedClient) WriteFloat32s(address uint16, values []float32, order ByteOrder) (err error) {
+	return tc.WriteFloat32sContext(context.Background(), address, values, order)
+}
+
+// WriteFloat32sContext is WriteFloat32s bounded by ctx.
+func (tc *TypedClient) WriteFloat32sContext(ctx context.Context, address uint16, values []float32, order ByteOrder) (err error) {
+	return writeValues(ctx, tc.Client, address, values, 4, func(b []byte, v float32) {
+		order.PutUint32(b, math.Float32bits(v))
+	})
+}
+
+// WriteInt64s writes int64 values to holding registers.
+func (tc *TypedClient) WriteInt64s(address uint16, values []int64, order ByteOrder) (err error) {
+	return tc.WriteInt64sContext(context.Background(), address, values, order)
+}
+
+// WriteInt64sContext is WriteInt64s bounded by ctx.
+func (tc *TypedClient) WriteInt64sContext(ctx context.Context, address uint16, values []int64, order ByteOrder) (err error) {
+	return writeValues(ctx, tc.Client, address, values, 8, func(b []byte, v int64) {
+		order.PutUint64(b, uint64(v))
+	})
+}
+
+// WriteUint64s writes uint64 values to holding registers.
+func (tc *TypedClient) WriteUint64s(address uint16, values []uint64, order ByteOrder) (err error) {
+	return tc.WriteUint64sContext(context.Background(), address, values, order)
+}
+
+// WriteUint64sContext is WriteUint64s bounded by ctx.
+func (tc *TypedClient) WriteUint64sContext(ctx context.Context, address uint16, values []uint64, order ByteOrder) (err error) {
+	return writeValues(ctx, tc.Client, address, values, 8, order.PutUint64)
+}
+
+// WriteFloat64s writes float64 values to holding registers.
+func (tc *TypedClient) WriteFloat64s(address uint16, values []float64, order ByteOrder) (err error) {
+	return tc.WriteFloat64sContext(context.Background(), address, values, order)
+}
+
+// WriteFloat64sContext is WriteFloat64s bounded by ctx.
+func (tc *TypedClient) WriteFloat64sContext(ctx context.Context, address uint16, values []float64, order ByteOrder) (err error) {
+	return writeValues(ctx, tc.Client, address, values, 8, func(b []byte, v float64) {
+		order.PutUint64(b, math.Float64bits(v))
+	})
+}
+
+// readValues reads count values of size bytes each with read and decodes them.
+func readValues[T any](ctx context.Context, read func(ctx context.Context, address, quantity uint16) ([]byte, error),
+	address, count uint16, size int, decode func(b []byte) T) (values []T, err error) {
+	quantity := int(count) * size / 2
+	if count < 1 || quantity > MaxReadRegisters {
+		err = fmt.Errorf("modbus: count '%v' must be between '%v' and '%v'", count, 1, MaxReadRegisters*2/size)
+		return
+	}
+	results, err := read(ctx, address, uint16(quantity))
+	if err != nil {
+		return
+	}
+	values = make([]T, count)
+	for i := range values {
+		values[i] = decode(results[i*size:])
+	}
+	return
+}
+
+// writeValues encodes values of size bytes each and writes them to holding registers.
+func writeValues[T any](ctx context.Context, client ClientContext, address uint16, values []T, size int, encode func(b []byte, v T)) (err error) {
+	quantity := len(values) * size / 2
+	if len(values) < 1 || quantity > MaxWriteRegisters {
+		err = fmt.Errorf("modbus: count '%v' must be between '%v' and '%v'", len(values), 1, MaxWriteRegisters*2/size)
+		return
+	}
+	data := make([]byte, len(values)*size)
+	for i, v := range values {
+		encode(data[i*size:], v)
+	}
+	_, err = client.WriteMultipleRegistersContext(ctx, address, uint16(quantity), data)
+	return
+}
@@ -0,0 +1,108 @@
+package modbus
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestByteOrder(t *testing.T) {
+	tests := []struct {
+		order ByteOrder
+		// 123.456 as float32 and float64
+		float32Bytes []byte
+		float64Bytes []byte
+	}{
+		{ABCD, []byte{0x42, 0xF6, 0xE9, 0x79}, []byte{0x40, 0x5E, 0xDD, 0x2F, 0x1A, 0x9F, 0xBE, 0x77}},
+		{CDAB, []byte{0xE9, 0x79, 0x42, 0xF6}, []byte{0xBE, 0x77, 0x1A, 0x9F, 0xDD, 0x2F, 0x40, 0x5E}},
+		{BADC, []byte{0xF6, 0x42, 0x79, 0xE9}, []byte{0x5E, 0x40, 0x2F, 0xDD, 0x9F, 0x1A, 0x77, 0xBE}},
+		{DCBA, []byte{0x79, 0xE9, 0xF6, 0x42}, []byte{0x77, 0xBE, 0x9F, 0x1A, 0x2F, 0xDD, 0x5E, 0x40}},
+	}
+	for _, test := range tests {
+		t.Run(test.order.String(), func(t *testing.T) {
+			if v := math.Float32frombits(test.order.Uint32(test.float32Bytes)); v != 123.456 {
+				t.Fatalf("float32 '%v', expected '%v'", v, 123.456)
+			}
+			if v := math.Float64frombits(test.order.Uint64(test.float64Bytes)); v != 123.456 {
+				t.Fatalf("float64 '%v', expected '%v'", v, 123.456)
+			}
+			b := make([]byte, 8)
+			test.order.PutUint32(b, math.Float32bits(123.456))
+			if !bytes.Equal(b[:4], test.float32Bytes) {
+				t.Fatalf("float32 bytes % x, expected % x", b[:4], test.float32Bytes)
+			}
+			test.order.PutUint64(b, math.Float64bits(123.456))
+			if !bytes.Equal(b, test.float64Bytes) {
+				t.Fatalf("float64 bytes % x, expected % x", b, test.float64Bytes)
+			}
+			// Words and bytes all differ
+			test.order.PutUint32(b, uint32(0x0102A3B4))
+			if v := test.order.Uint32(b); v != 0x0102A3B4 {
+				t.Fatalf("uint32 '%x' does not round trip", v)
+			}
+		})
+	}
+	if v := int32(CDAB.Uint32([]byte{0xFF, 0xFE, 0xFF, 0xFF})); v != -2 {
+		t.Fatalf("int32 '%v', expected '%v'", v, -2)
+	}
+	if v := BADC.Uint16([]byte{0x34, 0x12}); v != 0x1234 {
+		t.Fatalf("uint16 '%x', expected '%x'", v, 0x1234)
+	}
+}
+
+func TestTypedClient(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := NewMemoryHandler(0, 0, 32, 0)
+	server := NewServer("", memory)
+	go server.Serve(l)
+	defer server.Close()
+
+	handler := NewTCPClientHandler(l.Addr().String())
+	handler.Timeout = 5 * time.Second
+	handler.SlaveId = 1
+	defer handler.Close()
+	typed := NewTypedClient(NewClient(handler))
+
+	if err = typed.WriteFloat32s(0, []float32{123.456, -1}, CDAB); err != nil {
+		t.Fatal(err)
+	}
+	if memory.HoldingRegisters[0] != 0xE979 || memory.HoldingRegisters[1] != 0x42F6 {
+		t.Fatalf("registers % x, expected e979 42f6", memory.HoldingRegisters[:2])
+	}
+	float32s, err := typed.ReadFloat32s(0, 2, CDAB)
+	if err != nil || float32s[0] != 123.456 || float32s[1] != -1 {
+		t.Fatalf("float32 '%v', error '%v'", float32s, err)
+	}
+	if err = typed.WriteFloat64s(4, []float64{123.456}, DCBA); err != nil {
+		t.Fatal(err)
+	}
+	float64s, err := typed.ReadFloat64s(4, 1, DCBA)
+	if err != nil || float64s[0] != 123.456 {
+		t.Fatalf("float64 '%v', error '%v'", float64s, err)
+	}
+	if err = typed.WriteInt32s(8, []int32{-2, math.MaxInt32}, BADC); err != nil {
+		t.Fatal(err)
+	}
+	int32s, err := typed.ReadInt32s(8, 2, BADC)
+	if err != nil || int32s[0] != -2 || int32s[1] != math.MaxInt32 {
+		t.Fatalf("int32 '%v', error '%v'", int32s, err)
+	}
+	if err = typed.WriteUint32s(12, []uint32{0xAABBCCDD}, ABCD); err != nil {
+		t.Fatal(err)
+	}
+	if memory.HoldingRegisters[12] != 0xAABB || memory.HoldingRegisters[13] != 0xCCDD {
+		t.Fatalf("registers % x, expected aabb ccdd", memory.HoldingRegisters[12:14])
+	}
+	uint32s, err := typed.ReadUint32s(12, 1, ABCD)
+	if err != nil || uint32s[0] != 0xAABBCCDD {
+		t.Fatalf("uint32 '%x', error '%v'", uint32s, err)
+	}
+	if _, err = typed.ReadFloat32s(0, 63, ABCD); err == nil {
+		t.Fatal("reading more than 125 registers must fail")
+	}
+}
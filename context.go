@@ -0,0 +1,58 @@
+package modbus
+
+import (
+	"context"
+	"time"
+)
+
+// aLongTimeAgo is a non-zero time in the past, used to abort pending I/O
+// on connections supporting deadlines.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// sleepContext pauses the current goroutine for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// watchContext calls interrupt from another goroutine if ctx is done before
+// the returned stop function is called. Once stop returns, interrupt is
+// guaranteed not to be running; stop reports whether it has been called.
+func watchContext(ctx context.Context, interrupt func()) (stop func() bool) {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	stopc := make(chan struct{})
+	result := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			interrupt()
+			result <- true
+		case <-stopc:
+			result <- false
+		}
+	}()
+	return func() bool {
+		close(stopc)
+		return <-result
+	}
+}
+
+// contextDeadline returns the earlier of the deadline given by timeout
+// (zero meaning none) and the deadline of ctx.
+func contextDeadline(ctx context.Context, timeout time.Time) time.Time {
+	if deadline, ok := ctx.Deadline(); ok && (timeout.IsZero() || deadline.Before(timeout)) {
+		return deadline
+	}
+	return timeout
+}
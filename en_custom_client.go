@@ -21,6 +21,7 @@ func NewENRtuClientHandler(address string) *ENRtuClientHandler {
 	handler.Address = address
 	handler.Timeout = serialTimeout
 	handler.IdleTimeout = serialIdleTimeout
+	handler.TurnaroundDelay = serialTurnaroundDelay
 	return handler
 }
 
@@ -44,6 +45,14 @@ type enRtuPackager struct {
 	GunId   byte
 }
 
+func (mb *enRtuPackager) slaveId() byte {
+	return mb.SlaveId
+}
+
+func (mb *enRtuPackager) broadcasts() bool {
+	return true
+}
+
 // dataBlock creates a sequence of uint16 data.
 func (mb *enRtuPackager) dataBlock(value ...uint16) []byte {
 	data := make([]byte, 2*len(value)+1)
@@ -74,6 +83,11 @@ func (mb *enRtuPackager) DataBlockSuffix(suffix []byte, value ...uint16) []byte
 //	Data            : 0 up to 252 bytes
 //	CRC             : 2 byte
 func (mb *enRtuPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
+	return mb.encodeSlave(pdu, mb.SlaveId)
+}
+
+// encodeSlave is Encode addressing the PDU to slaveId.
+func (mb *enRtuPackager) encodeSlave(pdu *ProtocolDataUnit, slaveId byte) (adu []byte, err error) {
 	length := len(pdu.Data) + 4
 	if length > rtuMaxSize {
 		err = fmt.Errorf("modbus: length of data '%v' must not be bigger than '%v'", length, rtuMaxSize)
@@ -81,7 +95,7 @@ func (mb *enRtuPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
 	}
 	adu = make([]byte, length)
 
-	adu[0] = mb.SlaveId
+	adu[0] = slaveId
 	adu[1] = pdu.FunctionCode
 	copy(adu[2:], pdu.Data)
 
@@ -119,7 +133,7 @@ func (mb *enRtuPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	crc.reset().pushBytes(adu[0 : length-2])
 	checksum := uint16(adu[length-1])<<8 | uint16(adu[length-2])
 	if checksum != crc.value() {
-		err = fmt.Errorf("modbus: response crc '%v' does not match expected '%v'", checksum, crc.value())
+		err = &ChecksumError{Name: "crc", Received: checksum, Expected: crc.value()}
 		return
 	}
 	// Function code & data
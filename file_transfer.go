@@ -0,0 +1,148 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+const (
+	// Records of a single sub-request fitting in a PDU: the read response
+	// carries the file response length and the reference type after the
+	// byte count, the write request the data length and a 7 bytes
+	// sub-request header.
+	fileTransferReadRecords  = (fileReadMaxLength - 2) / 2
+	fileTransferWriteRecords = (fileRecordMaxLength - 8) / 2
+)
+
+// FileTransfer streams data to and from a file of a remote device using
+// file record access. The data is split into records which fit in a PDU
+// and transferred one request at a time.
+type FileTransfer struct {
+	Client     ClientContext
+	FileNumber uint16
+	// First record of the transfer
+	RecordNumber uint16
+	// Number of bytes read from the file by WriteTo. If zero, records are
+	// read until the device reports an illegal data address or the last
+	// record number is reached.
+	Size int64
+	// Progress, if set, is called after each request with the number of
+	// bytes transferred so far.
+	Progress func(transferred int64)
+}
+
+// NewFileTransfer allocates a FileTransfer of the whole file.
+func NewFileTransfer(client ClientContext, fileNumber uint16) *FileTransfer {
+	return &FileTransfer{
+		Client:     client,
+		FileNumber: fileNumber,
+	}
+}
+
+// ReadFrom writes the data read from r until EOF to the file. An odd
+// trailing byte is padded with zero to fill the last record.
+func (ft *FileTransfer) ReadFrom(r io.Reader) (n int64, err error) {
+	return ft.ReadFromContext(context.Background(), r)
+}
+
+// ReadFromContext is ReadFrom bounded by ctx.
+func (ft *FileTransfer) ReadFromContext(ctx context.Context, r io.Reader) (n int64, err error) {
+	var data [2 * fileTransferWriteRecords]byte
+	record := int(ft.RecordNumber)
+	for {
+		m, readErr := io.ReadFull(r, data[:])
+		if m > 0 {
+			length := (m + 1) / 2
+			if m%2 != 0 {
+				data[m] = 0
+			}
+			if record+length-1 > fileRecordMaxNumber {
+				err = fmt.Errorf("modbus: record number '%v' must not be bigger than '%v'", record+length-1, fileRecordMaxNumber)
+				return
+			}
+			err = ft.Client.WriteFileRecordContext(ctx, []FileRecord{{
+				FileNumber:   ft.FileNumber,
+				RecordNumber: uint16(record),
+				Data:         data[:2*length],
+			}})
+			if err != nil {
+				return
+			}
+			record += length
+			n += int64(m)
+			ft.progress(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			return
+		}
+		if readErr != nil {
+			err = readErr
+			return
+		}
+	}
+}
+
+// WriteTo reads the file and writes its data to w.
+func (ft *FileTransfer) WriteTo(w io.Writer) (n int64, err error) {
+	return ft.WriteToContext(context.Background(), w)
+}
+
+// WriteToContext is WriteTo bounded by ctx.
+func (ft *FileTransfer) WriteToContext(ctx context.Context, w io.Writer) (n int64, err error) {
+	record := int(ft.RecordNumber)
+	// Shrunk when probing the end of a file of unknown size
+	maxLength := fileTransferReadRecords
+	for (ft.Size <= 0 || n < ft.Size) && record <= fileRecordMaxNumber {
+		length := maxLength
+		if ft.Size > 0 && (ft.Size-n+1)/2 < int64(length) {
+			length = int((ft.Size - n + 1) / 2)
+		}
+		if record+length-1 > fileRecordMaxNumber {
+			length = fileRecordMaxNumber + 1 - record
+		}
+		var results [][]byte
+		results, err = ft.Client.ReadFileRecordContext(ctx, []FileRecord{{
+			FileNumber:   ft.FileNumber,
+			RecordNumber: uint16(record),
+			RecordLength: uint16(length),
+		}})
+		if err != nil {
+			if ft.Size <= 0 && isException(err, ExceptionCodeIllegalDataAddress) {
+				if length > 1 {
+					maxLength = length / 2
+					err = nil
+					continue
+				}
+				// End of file
+				err = nil
+			}
+			return
+		}
+		data := results[0]
+		if ft.Size > 0 && ft.Size-n < int64(len(data)) {
+			data = data[:ft.Size-n]
+		}
+		var m int
+		m, err = w.Write(data)
+		n += int64(m)
+		if err != nil {
+			return
+		}
+		record += length
+		ft.progress(n)
+	}
+	return
+}
+
+func (ft *FileTransfer) progress(transferred int64) {
+	if ft.Progress != nil {
+		ft.Progress(transferred)
+	}
+}
+
+// isException reports whether err is a modbus exception with the given code.
+func isException(err error, exceptionCode byte) bool {
+	mbError, ok := err.(*ModbusError)
+	return ok && mbError.ExceptionCode == exceptionCode
+}
@@ -0,0 +1,229 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Default number of requests waiting for the bus
+const gatewayQueueSize = 32
+
+// Gateway is a Modbus TCP server forwarding the requests of its clients to
+// the slaves of a serial line, such as a RTUClientHandler or a
+// RTUOverTcpClientHandler. Requests are queued and sent one at a time, in
+// the order they are received. The settings must be set before serving.
+//
+// The slaves do not answer a broadcast, a request to slave id 0 of the
+// line. As a Modbus TCP client waits for a response to each request, the
+// gateway answers a broadcast once sent on the line, with the response a
+// slave would have sent.
+type Gateway struct {
+	// Listen address, e.g. ":502"
+	Address string
+	// Handler of the line, the requests are sent to
+	Handler ClientHandler
+	// SlaveIds maps the unit ids of the requests to the slave ids of the
+	// line. Unit ids missing from the map are answered with the gateway path
+	// unavailable exception. If nil, the unit id is the slave id.
+	SlaveIds map[byte]byte
+	// Maximum number of requests waiting for the line, further requests are
+	// answered with the server device busy exception.
+	QueueSize int
+	// Idle timeout to close a client connection
+	IdleTimeout time.Duration
+	// Time an attempt of a request may take on the line, defaults to the
+	// timeout of the handler. A request may take the attempts allowed by the
+	// retry policy and the delays between them, and waits for the requests
+	// queued before. It is aborted when not answered in time, and answered
+	// with the target failed to respond exception.
+	Timeout time.Duration
+	// Transmission logger
+	Logger Logger
+
+	options []ClientOption
+	client  *client
+	server  Server
+	timeout time.Duration
+
+	initOnce sync.Once
+	queue    chan *gatewayRequest
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// gatewayRequest is a request waiting for the line.
+type gatewayRequest struct {
+	slave    *client
+	request  *ProtocolDataUnit
+	response chan *ProtocolDataUnit
+	// Time the client stops waiting for the response
+	deadline time.Time
+}
+
+// NewGateway allocates a new Gateway sending the requests with handler.
+// The options apply to the client sending the requests, e.g. a retry
+// policy or a circuit breaker.
+func NewGateway(address string, handler ClientHandler, options ...ClientOption) *Gateway {
+	return &Gateway{
+		Address:     address,
+		Handler:     handler,
+		QueueSize:   gatewayQueueSize,
+		IdleTimeout: tcpIdleTimeout,
+		Timeout:     handlerTimeout(handler),
+		options:     options,
+	}
+}
+
+// handlerTimeout returns the timeout of the handler of a line, or the
+// default serial timeout if unknown.
+func handlerTimeout(handler ClientHandler) time.Duration {
+	switch h := handler.(type) {
+	case *RTUClientHandler:
+		return h.Timeout
+	case *ASCIIClientHandler:
+		return h.Timeout
+	case *RTUOverTcpClientHandler:
+		return h.Timeout
+	case *ASCIIOverTcpClientHandler:
+		return h.Timeout
+	}
+	return serialTimeout
+}
+
+func (g *Gateway) init() {
+	g.client = NewClient(g.Handler, g.options...).(*client)
+	size := g.QueueSize
+	if size <= 0 {
+		size = gatewayQueueSize
+	}
+	g.queue = make(chan *gatewayRequest, size)
+	g.timeout = g.Timeout
+	if g.timeout <= 0 {
+		g.timeout = handlerTimeout(g.Handler)
+	}
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	g.server.IdleTimeout = g.IdleTimeout
+	g.server.Logger = g.Logger
+	g.server.serve = g.forward
+	go g.run()
+}
+
+// ListenAndServe listens on Address and forwards the requests of incoming
+// connections until Close is called.
+func (g *Gateway) ListenAndServe() error {
+	l, err := net.Listen("tcp", g.Address)
+	if err != nil {
+		return err
+	}
+	return g.Serve(l)
+}
+
+// Serve accepts connections on the listener and serves each of them in its
+// own goroutine. It always returns a non-nil error and closes l.
+func (g *Gateway) Serve(l net.Listener) error {
+	g.initOnce.Do(g.init)
+	return g.server.Serve(l)
+}
+
+// Close stops listening, aborts the request in progress, closes all client
+// connections and waits for their goroutines to return.
+func (g *Gateway) Close() error {
+	g.initOnce.Do(g.init)
+	g.cancel()
+	return g.server.Close()
+}
+
+// forward queues the request for the slave mapped to the unit id and waits
+// for the response.
+func (g *Gateway) forward(unitId byte, request *ProtocolDataUnit) *ProtocolDataUnit {
+	slaveId := unitId
+	if g.SlaveIds != nil {
+		var ok bool
+		if slaveId, ok = g.SlaveIds[unitId]; !ok {
+			g.Debugf("modbus: no slave mapped to unit id '%v'", unitId)
+			return exceptionResponse(request.FunctionCode, &ModbusError{ExceptionCode: ExceptionCodeGatewayPathUnavailable})
+		}
+	}
+	slave := g.client.WithSlave(slaveId).(*client)
+	if slave.broadcast() && !broadcastable(request.FunctionCode) {
+		g.Debugf("modbus: function '%v' of unit id '%v' must not be broadcast", request.FunctionCode, unitId)
+		return exceptionResponse(request.FunctionCode, &ModbusError{ExceptionCode: ExceptionCodeGatewayPathUnavailable})
+	}
+	// The connection reuses its buffer, while the request may still be
+	// queued when the gateway is closed
+	req := &gatewayRequest{
+		slave: slave,
+		request: &ProtocolDataUnit{
+			FunctionCode: request.FunctionCode,
+			Data:         append([]byte(nil), request.Data...),
+		},
+		response: make(chan *ProtocolDataUnit, 1),
+	}
+	// The requests queued before and the one in progress are sent first
+	duration := g.client.retry.maxDuration(req.request, g.timeout)
+	req.deadline = time.Now().Add(duration * time.Duration(len(g.queue)+2))
+	select {
+	case g.queue <- req:
+	default:
+		g.Debugf("modbus: queue full, rejecting request to unit id '%v'", unitId)
+		return exceptionResponse(request.FunctionCode, &ModbusError{ExceptionCode: ExceptionCodeServerDeviceBusy})
+	}
+	timer := time.NewTimer(time.Until(req.deadline))
+	defer timer.Stop()
+	select {
+	case response := <-req.response:
+		return response
+	case <-timer.C:
+		// A response received just before the deadline wins
+		select {
+		case response := <-req.response:
+			return response
+		default:
+		}
+		g.Debugf("modbus: request to unit id '%v' timed out", unitId)
+	case <-g.ctx.Done():
+	}
+	return exceptionResponse(request.FunctionCode, &ModbusError{ExceptionCode: ExceptionCodeGatewayTargetDeviceFailedToRespond})
+}
+
+// run sends the queued requests one at a time until the gateway is closed.
+func (g *Gateway) run() {
+	for {
+		select {
+		case req := <-g.queue:
+			if time.Now().After(req.deadline) {
+				// The client was answered the target failed to respond
+				continue
+			}
+			req.response <- g.send(req)
+		case <-g.ctx.Done():
+			return
+		}
+	}
+}
+
+// send sends the request on the line and returns the response of the
+// slave, its exception, or the target failed to respond exception.
+func (g *Gateway) send(req *gatewayRequest) *ProtocolDataUnit {
+	ctx, cancel := context.WithDeadline(g.ctx, req.deadline)
+	defer cancel()
+	response, err := req.slave.sendContext(ctx, req.request)
+	if err == nil {
+		return response
+	}
+	var mbError *ModbusError
+	if errors.As(err, &mbError) {
+		return exceptionResponse(req.request.FunctionCode, mbError)
+	}
+	g.Debugf("modbus: slave '%v' failed to respond: %v", req.slave.slave, err)
+	return exceptionResponse(req.request.FunctionCode, &ModbusError{ExceptionCode: ExceptionCodeGatewayTargetDeviceFailedToRespond})
+}
+
+func (g *Gateway) Debugf(format string, v ...interface{}) {
+	if g.Logger != nil {
+		g.Logger.Debugf(format, v...)
+	}
+}
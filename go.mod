@@ -2,4 +2,4 @@ module github.com/weiheng-tech/modbus
 
 go 1.18
 
-require github.com/jifanchn/serial v0.1.0 // indirect
+require github.com/jifanchn/serial v0.1.0
@@ -0,0 +1,29 @@
+package modbus
+
+// Longitudinal Redundancy Checking
+type lrc struct {
+	sum uint8
+}
+
+func (lrc *lrc) reset() *lrc {
+	lrc.sum = 0
+	return lrc
+}
+
+func (lrc *lrc) pushByte(b byte) *lrc {
+	lrc.sum += b
+	return lrc
+}
+
+func (lrc *lrc) pushBytes(bs []byte) *lrc {
+	var b byte
+	for _, b = range bs {
+		lrc.sum += b
+	}
+	return lrc
+}
+
+// value returns the two's complement of the sum of all bytes.
+func (lrc *lrc) value() byte {
+	return uint8(-int8(lrc.sum))
+}
@@ -0,0 +1,613 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Table is one of the four data tables of a device.
+type Table byte
+
+const (
+	TableCoils Table = iota + 1
+	TableDiscreteInputs
+	TableHoldingRegisters
+	TableInputRegisters
+)
+
+func (table Table) String() string {
+	switch table {
+	case TableCoils:
+		return "coil"
+	case TableDiscreteInputs:
+		return "di"
+	case TableHoldingRegisters:
+		return "hr"
+	case TableInputRegisters:
+		return "ir"
+	}
+	return fmt.Sprintf("Table(%d)", byte(table))
+}
+
+// isBit reports whether the table holds bits rather than registers.
+func (table Table) isBit() bool {
+	return table == TableCoils || table == TableDiscreteInputs
+}
+
+// readLimit returns the maximum quantity of a single read of the table.
+func (table Table) readLimit() int {
+	if table.isBit() {
+		return MaxReadBits
+	}
+	return MaxReadRegisters
+}
+
+// Register types of the modbus struct tag and the number of registers they occupy
+var mappingTypes = map[string]int{
+	"int16":   1,
+	"uint16":  1,
+	"int32":   2,
+	"uint32":  2,
+	"float32": 2,
+	"int64":   4,
+	"uint64":  4,
+	"float64": 4,
+}
+
+// mappedField is a struct field mapped to a table by its modbus tag.
+type mappedField struct {
+	name    string
+	index   int
+	table   Table
+	address uint16
+	// Register type, or "bool" for coils and discrete inputs
+	kind string
+	// Number of bits or registers
+	size  int
+	order ByteOrder
+	scale float64
+}
+
+// mappingKey identifies the data of a field in a Mapper snapshot.
+type mappingKey struct {
+	table   Table
+	address uint16
+}
+
+// Unmarshal reads the device data mapped by the modbus tags of the fields
+// of the struct pointed to by v and stores it in the fields. Fields are
+// grouped into as few requests as the quantity limits allow.
+//
+// A tag is made of comma separated options, the table and the address
+// being first:
+//
+//	Value   float64 `modbus:"hr,100,float32,cdab,scale=0.1"`
+//	Running bool    `modbus:"coil,12"`
+//
+// The table is one of coil, di, hr and ir. The address is the 0-based
+// protocol address sent in the request, in decimal or 0x prefixed
+// hexadecimal. It is not a Modicon reference and is never converted:
+// "hr,40100" is the holding register at protocol address 40100, while the
+// Modicon reference 40100 is written "hr,99".
+//
+// Register fields may specify the register type (int16, uint16, int32,
+// uint32, float32, int64, uint64 or float64, defaulting to the type of the
+// field), the byte order (abcd, cdab, badc or dcba, defaulting to abcd) and
+// a scale the register value is multiplied by when read and divided by when
+// written. Fields of coils and discrete inputs must be bool.
+func Unmarshal(client ClientContext, v interface{}) error {
+	return UnmarshalContext(context.Background(), client, v)
+}
+
+// UnmarshalContext is Unmarshal bounded by ctx.
+func UnmarshalContext(ctx context.Context, client ClientContext, v interface{}) error {
+	return readMapping(ctx, client, v, nil)
+}
+
+// Marshal writes the fields of the struct pointed to by v which are mapped
+// to coils and holding registers, see Unmarshal for the tag format.
+// Adjacent fields are written in a single request.
+func Marshal(client ClientContext, v interface{}) error {
+	return MarshalContext(context.Background(), client, v)
+}
+
+// MarshalContext is Marshal bounded by ctx.
+func MarshalContext(ctx context.Context, client ClientContext, v interface{}) error {
+	return writeMapping(ctx, client, v, nil)
+}
+
+// Mapper reads and writes structs like Unmarshal and Marshal, but keeps the
+// data last read from or written to the device, so that only the fields
+// changed since are written back.
+type Mapper struct {
+	Client ClientContext
+
+	mu       sync.Mutex
+	snapshot map[mappingKey][]byte
+}
+
+// NewMapper allocates a Mapper of the device behind client.
+func NewMapper(client ClientContext) *Mapper {
+	return &Mapper{
+		Client:   client,
+		snapshot: make(map[mappingKey][]byte),
+	}
+}
+
+// Read reads the struct pointed to by v and remembers its data.
+func (m *Mapper) Read(v interface{}) error {
+	return m.ReadContext(context.Background(), v)
+}
+
+// ReadContext is Read bounded by ctx.
+func (m *Mapper) ReadContext(ctx context.Context, v interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return readMapping(ctx, m.Client, v, m.snapshot)
+}
+
+// Write writes the fields of the struct pointed to by v whose data differ
+// from the data last read or written. Fields never read are written.
+func (m *Mapper) Write(v interface{}) error {
+	return m.WriteContext(context.Background(), v)
+}
+
+// WriteContext is Write bounded by ctx.
+func (m *Mapper) WriteContext(ctx context.Context, v interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return writeMapping(ctx, m.Client, v, m.snapshot)
+}
+
+// Reset forgets the data read and written, so that the next Write writes
+// all fields.
+func (m *Mapper) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.snapshot = make(map[mappingKey][]byte)
+}
+
+// readMapping reads the fields of v, recording their data in snapshot if
+// not nil.
+func readMapping(ctx context.Context, client ClientContext, v interface{}, snapshot map[mappingKey][]byte) (err error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		err = fmt.Errorf("modbus: unmarshal target '%T' is not a pointer to a struct", v)
+		return
+	}
+	value = value.Elem()
+	fields, err := parseMapping(value.Type())
+	if err != nil {
+		return
+	}
+	// Registers between fields may not exist, only contiguous fields are merged
+	points := make([]Point, len(fields))
+	for i, f := range fields {
+		points[i] = Point{Table: f.table, Address: f.address, Quantity: uint16(f.size)}
+	}
+	var planner Planner
+	plan, err := planner.Plan(points)
+	if err != nil {
+		return
+	}
+	results, err := plan.ExecuteContext(ctx, client)
+	if err != nil {
+		return
+	}
+	for i, f := range fields {
+		raw := results[i]
+		if err = f.decode(raw, value.Field(f.index)); err != nil {
+			return
+		}
+		if snapshot != nil {
+			snapshot[mappingKey{f.table, f.address}] = raw
+		}
+	}
+	return
+}
+
+// writeMapping writes the writable fields of v, skipping those whose data
+// equal their snapshot if snapshot is not nil.
+func writeMapping(ctx context.Context, client ClientContext, v interface{}, snapshot map[mappingKey][]byte) (err error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		err = fmt.Errorf("modbus: marshal source '%T' is not a struct", v)
+		return
+	}
+	fields, err := parseMapping(value.Type())
+	if err != nil {
+		return
+	}
+	sortFields(fields)
+	var changed []mappedField
+	var raws [][]byte
+	for _, f := range fields {
+		if f.table != TableCoils && f.table != TableHoldingRegisters {
+			continue
+		}
+		var raw []byte
+		if raw, err = f.encode(value.Field(f.index)); err != nil {
+			return
+		}
+		if snapshot != nil {
+			if old, ok := snapshot[mappingKey{f.table, f.address}]; ok && bytes.Equal(old, raw) {
+				continue
+			}
+		}
+		if n := len(changed); n > 0 && changed[n-1].table == f.table && int(changed[n-1].address)+changed[n-1].size > int(f.address) {
+			err = fmt.Errorf("modbus: fields '%v' and '%v' overlap", changed[n-1].name, f.name)
+			return
+		}
+		changed = append(changed, f)
+		raws = append(raws, raw)
+	}
+	for start := 0; start < len(changed); {
+		// Extend the block while fields are adjacent and fit in a request
+		first := changed[start]
+		limit := MaxWriteRegisters
+		if first.table.isBit() {
+			limit = MaxWriteBits
+		}
+		end := int(first.address) + first.size
+		next := start + 1
+		for ; next < len(changed); next++ {
+			f := changed[next]
+			if f.table != first.table || int(f.address) != end || end+f.size-int(first.address) > limit {
+				break
+			}
+			end += f.size
+		}
+		quantity := uint16(end - int(first.address))
+		if first.table.isBit() {
+			data := make([]byte, (quantity+7)/8)
+			for i, raw := range raws[start:next] {
+				if raw[0] != 0 {
+					data[i/8] |= 1 << (i % 8)
+				}
+			}
+			_, err = client.WriteMultipleCoilsContext(ctx, first.address, quantity, data)
+		} else {
+			data := make([]byte, 0, 2*quantity)
+			for _, raw := range raws[start:next] {
+				data = append(data, raw...)
+			}
+			_, err = client.WriteMultipleRegistersContext(ctx, first.address, quantity, data)
+		}
+		if err != nil {
+			return
+		}
+		if snapshot != nil {
+			for i, f := range changed[start:next] {
+				snapshot[mappingKey{f.table, f.address}] = raws[start+i]
+			}
+		}
+		start = next
+	}
+	return
+}
+
+// readBlock reads quantity bits or registers of table.
+func readBlock(ctx context.Context, client ClientContext, table Table, address, quantity uint16) (results []byte, err error) {
+	switch table {
+	case TableCoils:
+		return client.ReadCoilsContext(ctx, address, quantity)
+	case TableDiscreteInputs:
+		return client.ReadDiscreteInputsContext(ctx, address, quantity)
+	case TableHoldingRegisters:
+		return client.ReadHoldingRegistersContext(ctx, address, quantity)
+	case TableInputRegisters:
+		return client.ReadInputRegistersContext(ctx, address, quantity)
+	}
+	err = fmt.Errorf("modbus: invalid table '%v'", table)
+	return
+}
+
+// sortFields sorts fields by table and address.
+func sortFields(fields []mappedField) {
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].table != fields[j].table {
+			return fields[i].table < fields[j].table
+		}
+		return fields[i].address < fields[j].address
+	})
+}
+
+// parseMapping returns the fields of struct type t having a modbus tag.
+func parseMapping(t reflect.Type) (fields []mappedField, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("modbus")
+		if !ok || tag == "-" {
+			continue
+		}
+		if sf.PkgPath != "" {
+			err = fmt.Errorf("modbus: field '%v' is not exported", sf.Name)
+			return
+		}
+		var f mappedField
+		if f, err = parseMappingTag(sf.Name, tag, sf.Type.Kind()); err != nil {
+			return
+		}
+		f.index = i
+		fields = append(fields, f)
+	}
+	return
+}
+
+// parseMappingTag parses the modbus tag of a field of the given kind.
+func parseMappingTag(name, tag string, kind reflect.Kind) (f mappedField, err error) {
+	f.name = name
+	f.scale = 1
+	options := strings.Split(tag, ",")
+	if len(options) < 2 {
+		err = fmt.Errorf("modbus: tag '%v' of field '%v' must start with a table and an address", tag, name)
+		return
+	}
+	switch strings.TrimSpace(options[0]) {
+	case "coil":
+		f.table = TableCoils
+	case "di":
+		f.table = TableDiscreteInputs
+	case "hr":
+		f.table = TableHoldingRegisters
+	case "ir":
+		f.table = TableInputRegisters
+	default:
+		err = fmt.Errorf("modbus: table '%v' of field '%v' must be one of coil, di, hr and ir", options[0], name)
+		return
+	}
+	address, err := strconv.ParseUint(strings.TrimSpace(options[1]), 0, 16)
+	if err != nil {
+		err = fmt.Errorf("modbus: address '%v' of field '%v' is invalid: %v", options[1], name, err)
+		return
+	}
+	f.address = uint16(address)
+	for _, option := range options[2:] {
+		option = strings.TrimSpace(option)
+		if _, ok := mappingTypes[option]; ok || option == "bool" {
+			f.kind = option
+			continue
+		}
+		switch strings.ToLower(option) {
+		case "abcd":
+			f.order = ABCD
+			continue
+		case "cdab":
+			f.order = CDAB
+			continue
+		case "badc":
+			f.order = BADC
+			continue
+		case "dcba":
+			f.order = DCBA
+			continue
+		}
+		if strings.HasPrefix(option, "scale=") {
+			if f.scale, err = strconv.ParseFloat(option[len("scale="):], 64); err != nil || f.scale == 0 {
+				err = fmt.Errorf("modbus: scale '%v' of field '%v' is invalid", option[len("scale="):], name)
+				return
+			}
+			continue
+		}
+		err = fmt.Errorf("modbus: option '%v' of field '%v' is unknown", option, name)
+		return
+	}
+	if f.table.isBit() {
+		if kind != reflect.Bool || (f.kind != "" && f.kind != "bool") || f.scale != 1 {
+			err = fmt.Errorf("modbus: field '%v' of table '%v' must be a bool", name, f.table)
+			return
+		}
+		f.kind = "bool"
+		f.size = 1
+		return
+	}
+	if f.kind == "" {
+		switch kind {
+		case reflect.Int8, reflect.Int16:
+			f.kind = "int16"
+		case reflect.Uint8, reflect.Uint16:
+			f.kind = "uint16"
+		case reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			f.kind = kind.String()
+		default:
+			err = fmt.Errorf("modbus: field '%v' of kind '%v' requires a register type", name, kind)
+			return
+		}
+	}
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		err = fmt.Errorf("modbus: field '%v' of kind '%v' cannot be mapped to registers", name, kind)
+		return
+	}
+	f.size = mappingTypes[f.kind]
+	return
+}
+
+// decode stores the value of the raw data of the field in v.
+func (f *mappedField) decode(raw []byte, v reflect.Value) error {
+	var n number
+	switch f.kind {
+	case "bool":
+		v.SetBool(raw[0] != 0)
+		return nil
+	case "int16":
+		n = signedNumber(int64(int16(f.order.Uint16(raw))))
+	case "uint16":
+		n = unsignedNumber(uint64(f.order.Uint16(raw)))
+	case "int32":
+		n = signedNumber(int64(int32(f.order.Uint32(raw))))
+	case "uint32":
+		n = unsignedNumber(uint64(f.order.Uint32(raw)))
+	case "float32":
+		n = floatNumber(float64(math.Float32frombits(f.order.Uint32(raw))))
+	case "int64":
+		n = signedNumber(int64(f.order.Uint64(raw)))
+	case "uint64":
+		n = unsignedNumber(f.order.Uint64(raw))
+	case "float64":
+		n = floatNumber(math.Float64frombits(f.order.Uint64(raw)))
+	}
+	if f.scale != 1 {
+		n = floatNumber(n.float() * f.scale)
+	}
+	if !n.store(v) {
+		return fmt.Errorf("modbus: value '%v' of field '%v' overflows '%v'", n, f.name, v.Type())
+	}
+	return nil
+}
+
+// encode returns the raw data of the value v of the field.
+func (f *mappedField) encode(v reflect.Value) (raw []byte, err error) {
+	if f.kind == "bool" {
+		if v.Bool() {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	}
+	n := loadNumber(v)
+	if f.scale != 1 {
+		n = floatNumber(n.float() / f.scale)
+	}
+	raw = make([]byte, 2*f.size)
+	ok := true
+	switch f.kind {
+	case "int16":
+		var i int64
+		i, ok = n.int(math.MinInt16, math.MaxInt16)
+		f.order.PutUint16(raw, uint16(i))
+	case "uint16":
+		var u uint64
+		u, ok = n.uint(math.MaxUint16)
+		f.order.PutUint16(raw, uint16(u))
+	case "int32":
+		var i int64
+		i, ok = n.int(math.MinInt32, math.MaxInt32)
+		f.order.PutUint32(raw, uint32(i))
+	case "uint32":
+		var u uint64
+		u, ok = n.uint(math.MaxUint32)
+		f.order.PutUint32(raw, uint32(u))
+	case "float32":
+		f.order.PutUint32(raw, math.Float32bits(float32(n.float())))
+	case "int64":
+		var i int64
+		i, ok = n.int(math.MinInt64, math.MaxInt64)
+		f.order.PutUint64(raw, uint64(i))
+	case "uint64":
+		var u uint64
+		u, ok = n.uint(math.MaxUint64)
+		f.order.PutUint64(raw, u)
+	case "float64":
+		f.order.PutUint64(raw, math.Float64bits(n.float()))
+	}
+	if !ok {
+		err = fmt.Errorf("modbus: value '%v' of field '%v' overflows '%v'", n, f.name, f.kind)
+	}
+	return
+}
+
+// number holds a signed, unsigned or floating point value while converting
+// between fields and registers.
+type number struct {
+	kind reflect.Kind
+	i    int64
+	u    uint64
+	f    float64
+}
+
+func signedNumber(i int64) number    { return number{kind: reflect.Int64, i: i} }
+func unsignedNumber(u uint64) number { return number{kind: reflect.Uint64, u: u} }
+func floatNumber(f float64) number   { return number{kind: reflect.Float64, f: f} }
+
+// loadNumber returns the value of the numeric field v.
+func loadNumber(v reflect.Value) number {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return signedNumber(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return unsignedNumber(v.Uint())
+	}
+	return floatNumber(v.Float())
+}
+
+func (n number) String() string {
+	switch n.kind {
+	case reflect.Int64:
+		return strconv.FormatInt(n.i, 10)
+	case reflect.Uint64:
+		return strconv.FormatUint(n.u, 10)
+	}
+	return strconv.FormatFloat(n.f, 'g', -1, 64)
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case reflect.Int64:
+		return float64(n.i)
+	case reflect.Uint64:
+		return float64(n.u)
+	}
+	return n.f
+}
+
+// int returns n rounded to an integer, reporting whether it is in [min, max].
+func (n number) int(min, max int64) (int64, bool) {
+	switch n.kind {
+	case reflect.Int64:
+		return n.i, n.i >= min && n.i <= max
+	case reflect.Uint64:
+		return int64(n.u), n.u <= uint64(max)
+	}
+	r := math.Round(n.f)
+	return int64(r), r >= float64(min) && r < float64(max)+1
+}
+
+// uint returns n rounded to an integer, reporting whether it is in [0, max].
+func (n number) uint(max uint64) (uint64, bool) {
+	switch n.kind {
+	case reflect.Int64:
+		return uint64(n.i), n.i >= 0 && uint64(n.i) <= max
+	case reflect.Uint64:
+		return n.u, n.u <= max
+	}
+	r := math.Round(n.f)
+	return uint64(r), r >= 0 && r < float64(max)+1
+}
+
+// store sets the numeric field v to n, reporting whether n fits in v.
+func (n number) store(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := n.int(math.MinInt64, math.MaxInt64)
+		if !ok || v.OverflowInt(i) {
+			return false
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, ok := n.uint(math.MaxUint64)
+		if !ok || v.OverflowUint(u) {
+			return false
+		}
+		v.SetUint(u)
+	default:
+		f := n.float()
+		if v.OverflowFloat(f) {
+			return false
+		}
+		v.SetFloat(f)
+	}
+	return true
+}
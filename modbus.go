@@ -8,6 +8,7 @@ Package modbus provides a client for MODBUS TCP and RTU/ASCII.
 package modbus
 
 import (
+	"context"
 	"fmt"
 	"io"
 )
@@ -27,6 +28,72 @@ const (
 	FuncCodeReadWriteMultipleRegisters = 23
 	FuncCodeMaskWriteRegister          = 22
 	FuncCodeReadFIFOQueue              = 24
+
+	// Diagnostics (serial line only)
+	FuncCodeReadExceptionStatus = 7
+	FuncCodeDiagnostics         = 8
+	FuncCodeGetCommEventCounter = 11
+	FuncCodeGetCommEventLog     = 12
+	FuncCodeReportServerId      = 17
+
+	// File record access
+	FuncCodeReadFileRecord  = 20
+	FuncCodeWriteFileRecord = 21
+
+	// Encapsulated interface transport
+	FuncCodeEncapsulatedInterfaceTransport = 43
+)
+
+const (
+	// Maximum quantities of a single request
+	MaxReadBits           = 2000
+	MaxWriteBits          = 1968
+	MaxReadRegisters      = 125
+	MaxWriteRegisters     = 123
+	MaxReadWriteRegisters = 121
+)
+
+const (
+	// Sub-function codes of Diagnostics
+	DiagSubFuncReturnQueryData                    = 0x00
+	DiagSubFuncRestartCommunicationsOption        = 0x01
+	DiagSubFuncReturnDiagnosticRegister           = 0x02
+	DiagSubFuncChangeAsciiInputDelimiter          = 0x03
+	DiagSubFuncForceListenOnlyMode                = 0x04
+	DiagSubFuncClearCountersAndDiagnosticRegister = 0x0A
+	DiagSubFuncReturnBusMessageCount              = 0x0B
+	DiagSubFuncReturnBusCommunicationErrorCount   = 0x0C
+	DiagSubFuncReturnBusExceptionErrorCount       = 0x0D
+	DiagSubFuncReturnServerMessageCount           = 0x0E
+	DiagSubFuncReturnServerNoResponseCount        = 0x0F
+	DiagSubFuncReturnServerNAKCount               = 0x10
+	DiagSubFuncReturnServerBusyCount              = 0x11
+	DiagSubFuncReturnBusCharacterOverrunCount     = 0x12
+	DiagSubFuncClearOverrunCounterAndFlag         = 0x14
+)
+
+const (
+	// MEI type of Read Device Identification
+	MEITypeReadDeviceIdentification = 14
+
+	// Read device id codes
+	ReadDeviceIdCodeBasic    = 1
+	ReadDeviceIdCodeRegular  = 2
+	ReadDeviceIdCodeExtended = 3
+	ReadDeviceIdCodeSpecific = 4
+)
+
+const (
+	// Basic device identification objects
+	DeviceIdObjectVendorName         = 0
+	DeviceIdObjectProductCode        = 1
+	DeviceIdObjectMajorMinorRevision = 2
+
+	// Regular device identification objects
+	DeviceIdObjectVendorUrl           = 3
+	DeviceIdObjectProductName         = 4
+	DeviceIdObjectModelName           = 5
+	DeviceIdObjectUserApplicationName = 6
 )
 
 const (
@@ -49,30 +116,45 @@ type ModbusError struct {
 
 // Error converts known modbus exception code to error message.
 func (e *ModbusError) Error() string {
-	var name string
-	switch e.ExceptionCode {
+	return fmt.Sprintf("modbus: exception '%v' (%s), function '%v'", e.ExceptionCode, exceptionName(e.ExceptionCode), e.FunctionCode)
+}
+
+// exceptionName returns the name of a known exception code.
+func exceptionName(exceptionCode byte) string {
+	switch exceptionCode {
 	case ExceptionCodeIllegalFunction:
-		name = "illegal function"
+		return "illegal function"
 	case ExceptionCodeIllegalDataAddress:
-		name = "illegal data address"
+		return "illegal data address"
 	case ExceptionCodeIllegalDataValue:
-		name = "illegal data value"
+		return "illegal data value"
 	case ExceptionCodeServerDeviceFailure:
-		name = "server device failure"
+		return "server device failure"
 	case ExceptionCodeAcknowledge:
-		name = "acknowledge"
+		return "acknowledge"
 	case ExceptionCodeServerDeviceBusy:
-		name = "server device busy"
+		return "server device busy"
 	case ExceptionCodeMemoryParityError:
-		name = "memory parity error"
+		return "memory parity error"
 	case ExceptionCodeGatewayPathUnavailable:
-		name = "gateway path unavailable"
+		return "gateway path unavailable"
 	case ExceptionCodeGatewayTargetDeviceFailedToRespond:
-		name = "gateway target device failed to respond"
-	default:
-		name = "unknown"
+		return "gateway target device failed to respond"
 	}
-	return fmt.Sprintf("modbus: exception '%v' (%s), function '%v'", e.ExceptionCode, name, e.FunctionCode)
+	return "unknown"
+}
+
+// ChecksumError is returned when the CRC or LRC of a response does not
+// match its content, usually because of noise on a serial line.
+type ChecksumError struct {
+	// Checksum name, crc or lrc
+	Name     string
+	Received uint16
+	Expected uint16
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("modbus: response %v '%v' does not match expected '%v'", e.Name, e.Received, e.Expected)
 }
 
 // ProtocolDataUnit (PDU) is independent of underlying communication layers.
@@ -95,3 +177,9 @@ type Transporter interface {
 	io.Closer
 	Send(request []byte) (response []byte, err error)
 }
+
+// TransporterContext is implemented by transporters which abort a
+// transaction when the context is canceled or its deadline is exceeded.
+type TransporterContext interface {
+	SendContext(ctx context.Context, request []byte) (response []byte, err error)
+}
@@ -5,6 +5,16 @@ import "encoding/binary"
 type basePackager struct {
 }
 
+// slavePackager is implemented by the packagers addressing requests to a
+// slave id.
+type slavePackager interface {
+	slaveId() byte
+	encodeSlave(pdu *ProtocolDataUnit, slaveId byte) (adu []byte, err error)
+	// broadcasts reports whether requests to slave id 0 are broadcast to
+	// all slaves, which do not answer.
+	broadcasts() bool
+}
+
 // DataBlock creates a sequence of uint16 data.
 func (mb *basePackager) DataBlock(value ...uint16) []byte {
 	data := make([]byte, 2*len(value))
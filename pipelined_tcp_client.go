@@ -0,0 +1,221 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Default number of requests pending on a pipelined connection
+const pipelinedMaxInFlight = 16
+
+// PipelinedTCPClientHandler implements Packager and Transporter interface.
+// Unlike TCPClientHandler, requests sent concurrently are pending on the
+// connection at the same time and responses are matched to requests by
+// transaction id, in any order.
+type PipelinedTCPClientHandler struct {
+	TcpPackager
+	pipelinedTcpTransporter
+}
+
+// NewPipelinedTCPClientHandler allocates a new PipelinedTCPClientHandler.
+func NewPipelinedTCPClientHandler(address string) *PipelinedTCPClientHandler {
+	h := &PipelinedTCPClientHandler{}
+	h.Address = address
+	h.Timeout = tcpTimeout
+	h.IdleTimeout = tcpIdleTimeout
+	h.MaxInFlight = pipelinedMaxInFlight
+	return h
+}
+
+// pipelinedResponse is the outcome of a pending request.
+type pipelinedResponse struct {
+	adu []byte
+	err error
+}
+
+// pipelinedRequest is a request waiting for its response on conn.
+type pipelinedRequest struct {
+	conn     net.Conn
+	response chan pipelinedResponse
+}
+
+// timeoutError is returned when no response is received in time.
+type timeoutError struct {
+	transactionId uint16
+	timeout       time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("modbus: no response to transaction id '%v' within '%v'", e.transactionId, e.timeout)
+}
+
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+// pipelinedTcpTransporter implements Transporter interface. The mutex
+// guards the connection and the pending requests only, a reader goroutine
+// per connection delivers the responses. Timeout applies to each request.
+type pipelinedTcpTransporter struct {
+	TcpPort
+	// Maximum number of requests pending at the same time, further requests
+	// wait for a response to be received. It must be set before sending.
+	MaxInFlight int
+
+	windowOnce sync.Once
+	window     chan struct{}
+	pending    map[uint16]*pipelinedRequest
+}
+
+// Send sends the request and waits for the response with the same
+// transaction id, while other requests may be sent.
+func (mb *pipelinedTcpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which gives up waiting when ctx is done.
+func (mb *pipelinedTcpTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	mb.windowOnce.Do(func() {
+		n := mb.MaxInFlight
+		if n <= 0 {
+			n = 1
+		}
+		mb.window = make(chan struct{}, n)
+	})
+	// Wait for a slot in the window
+	select {
+	case mb.window <- struct{}{}:
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+	defer func() { <-mb.window }()
+
+	transactionId := binary.BigEndian.Uint16(aduRequest)
+	request, err := mb.write(ctx, transactionId, aduRequest)
+	if err != nil || request == nil {
+		return
+	}
+	var expired <-chan time.Time
+	if mb.Timeout > 0 {
+		timer := time.NewTimer(mb.Timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case response := <-request.response:
+		aduResponse, err = response.adu, response.err
+		if err == nil {
+			mb.Debugf("modbus: received % x", aduResponse)
+		}
+		return
+	case <-expired:
+		err = &timeoutError{transactionId: transactionId, timeout: mb.Timeout}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	// A late response is discarded by the reader
+	mb.Mu.Lock()
+	if mb.pending[transactionId] == request {
+		delete(mb.pending, transactionId)
+	}
+	mb.Mu.Unlock()
+	return
+}
+
+// toleratesMismatch keeps the connection open when a response fails
+// verification: responses are matched by transaction id, so the other
+// requests pending on the connection are not affected.
+func (mb *pipelinedTcpTransporter) toleratesMismatch() bool {
+	return true
+}
+
+// write registers the request as pending and sends it, connecting first
+// if needed. The request is nil if it is not answered.
+func (mb *pipelinedTcpTransporter) write(ctx context.Context, transactionId uint16, aduRequest []byte) (request *pipelinedRequest, err error) {
+	mb.Mu.Lock()
+	defer mb.Mu.Unlock()
+
+	if mb.Conn == nil {
+		if err = mb.ConnectContext(ctx); err != nil {
+			return
+		}
+		go mb.readLoop(mb.Conn)
+	}
+	// Set timer to close when idle
+	mb.LastActivity = time.Now()
+	mb.StartCloseTimer()
+
+	if _, ok := mb.pending[transactionId]; ok {
+		err = fmt.Errorf("modbus: transaction id '%v' is already pending", transactionId)
+		return
+	}
+	if tcpResponseExpected(aduRequest) {
+		if mb.pending == nil {
+			mb.pending = make(map[uint16]*pipelinedRequest)
+		}
+		request = &pipelinedRequest{conn: mb.Conn, response: make(chan pipelinedResponse, 1)}
+		mb.pending[transactionId] = request
+	}
+
+	var timeout time.Time
+	if mb.Timeout > 0 {
+		timeout = mb.LastActivity.Add(mb.Timeout)
+	}
+	if err = mb.Conn.SetWriteDeadline(contextDeadline(ctx, timeout)); err == nil {
+		mb.Debugf("modbus: sending % x", aduRequest)
+		_, err = mb.Conn.Write(aduRequest)
+	}
+	if err != nil {
+		// A partial write leaves the stream unusable, the reader fails
+		// the other pending requests.
+		delete(mb.pending, transactionId)
+		_ = mb.ConnClose()
+		request = nil
+	}
+	return
+}
+
+// readLoop delivers the responses received on conn to the pending requests
+// until conn fails or is closed, then fails the requests still pending.
+func (mb *pipelinedTcpTransporter) readLoop(conn net.Conn) {
+	var err error
+	var data [tcpMaxLength]byte
+	for {
+		var adu []byte
+		if adu, err = readTcpFrame(conn, data[:]); err != nil {
+			break
+		}
+		aduResponse := make([]byte, len(adu))
+		copy(aduResponse, adu)
+		transactionId := binary.BigEndian.Uint16(aduResponse)
+		mb.Mu.Lock()
+		request, ok := mb.pending[transactionId]
+		if ok && request.conn == conn {
+			delete(mb.pending, transactionId)
+		}
+		mb.LastActivity = time.Now()
+		mb.Mu.Unlock()
+		if !ok || request.conn != conn {
+			mb.Debugf("modbus: discarding response to transaction id '%v' not pending", transactionId)
+			continue
+		}
+		request.response <- pipelinedResponse{adu: aduResponse}
+	}
+
+	mb.Mu.Lock()
+	defer mb.Mu.Unlock()
+	if mb.Conn == conn {
+		mb.Debugf("modbus: closing connection: %v", err)
+		_ = mb.ConnClose()
+	}
+	for transactionId, request := range mb.pending {
+		if request.conn == conn {
+			delete(mb.pending, transactionId)
+			request.response <- pipelinedResponse{err: err}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Point is a range of contiguous bits or registers of a table to read.
+type Point struct {
+	Table    Table
+	Address  uint16
+	Quantity uint16
+}
+
+// Block is a single read request of a plan.
+type Block struct {
+	Table    Table
+	Address  uint16
+	Quantity uint16
+}
+
+// Planner merges points into as few read requests as possible: points are
+// merged into blocks when the gap between them is small enough, and blocks
+// are split at the quantity limit of a request.
+type Planner struct {
+	// Maximum number of bits or registers between two points read in the
+	// same block. Devices may answer an illegal data address when a gap
+	// covers unmapped addresses.
+	MaxGap int
+	// Maximum quantity of registers and bits of a request, defaulting to
+	// the limits of the protocol, for devices supporting smaller requests.
+	MaxRegisters int
+	MaxBits      int
+}
+
+// Plan is the read requests covering a set of points.
+type Plan struct {
+	Points []Point
+	Blocks []Block
+	// Indexes of the points overlapping each block
+	covers [][]int
+}
+
+// Plan computes the blocks reading points.
+func (pl *Planner) Plan(points []Point) (plan *Plan, err error) {
+	order := make([]int, len(points))
+	for i, point := range points {
+		if point.Table < TableCoils || point.Table > TableInputRegisters {
+			err = fmt.Errorf("modbus: table '%v' of point '%v' is invalid", point.Table, i)
+			return
+		}
+		if point.Quantity < 1 || int(point.Address)+int(point.Quantity) > 0x10000 {
+			err = fmt.Errorf("modbus: quantity '%v' of point '%v' at address '%v' is out of range", point.Quantity, i, point.Address)
+			return
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := points[order[i]], points[order[j]]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Address < b.Address
+	})
+
+	plan = &Plan{Points: append([]Point(nil), points...)}
+	// Current block, empty if end is not greater than start
+	var table Table
+	var start, end int
+	flush := func() {
+		if end > start {
+			plan.Blocks = append(plan.Blocks, Block{Table: table, Address: uint16(start), Quantity: uint16(end - start)})
+		}
+		start, end = 0, 0
+	}
+	for _, i := range order {
+		point := points[i]
+		limit := pl.limit(point.Table)
+		pointStart := int(point.Address)
+		pointEnd := pointStart + int(point.Quantity)
+		if point.Table == table && end > start && pointStart <= end+pl.MaxGap && maxInt(end, pointEnd)-start <= limit {
+			end = maxInt(end, pointEnd)
+			continue
+		}
+		if point.Table == table && pointStart < end {
+			// Skip the part read by the current block
+			pointStart = end
+		}
+		flush()
+		table = point.Table
+		for pointEnd-pointStart > limit {
+			plan.Blocks = append(plan.Blocks, Block{Table: table, Address: uint16(pointStart), Quantity: uint16(limit)})
+			pointStart += limit
+		}
+		start, end = pointStart, pointEnd
+	}
+	flush()
+
+	plan.covers = make([][]int, len(plan.Blocks))
+	for b, block := range plan.Blocks {
+		blockEnd := int(block.Address) + int(block.Quantity)
+		for i, point := range points {
+			if point.Table == block.Table && int(point.Address) < blockEnd && int(point.Address)+int(point.Quantity) > int(block.Address) {
+				plan.covers[b] = append(plan.covers[b], i)
+			}
+		}
+	}
+	return
+}
+
+// limit returns the maximum quantity of a request to table.
+func (pl *Planner) limit(table Table) int {
+	limit := table.readLimit()
+	if table.isBit() {
+		if pl.MaxBits > 0 && pl.MaxBits < limit {
+			limit = pl.MaxBits
+		}
+	} else if pl.MaxRegisters > 0 && pl.MaxRegisters < limit {
+		limit = pl.MaxRegisters
+	}
+	return limit
+}
+
+// Execute reads the blocks of the plan and returns the data of each point,
+// in the order of Points. The data of registers is 2 bytes per register,
+// bits are packed 8 per byte starting with the least significant bit as
+// returned by ReadCoils.
+func (plan *Plan) Execute(client ClientContext) (results [][]byte, err error) {
+	return plan.ExecuteContext(context.Background(), client)
+}
+
+// ExecuteContext is Execute bounded by ctx.
+func (plan *Plan) ExecuteContext(ctx context.Context, client ClientContext) (results [][]byte, err error) {
+	data := make([][]byte, len(plan.Points))
+	for i, point := range plan.Points {
+		if point.Table.isBit() {
+			data[i] = make([]byte, (int(point.Quantity)+7)/8)
+		} else {
+			data[i] = make([]byte, 2*int(point.Quantity))
+		}
+	}
+	for b, block := range plan.Blocks {
+		var blockData []byte
+		if blockData, err = readBlock(ctx, client, block.Table, block.Address, block.Quantity); err != nil {
+			return
+		}
+		for _, i := range plan.covers[b] {
+			scatter(block, blockData, plan.Points[i], data[i])
+		}
+	}
+	results = data
+	return
+}
+
+// scatter copies the part of point read by block from the block data to
+// the point data.
+func scatter(block Block, blockData []byte, point Point, pointData []byte) {
+	start := maxInt(int(block.Address), int(point.Address))
+	end := int(block.Address) + int(block.Quantity)
+	if pointEnd := int(point.Address) + int(point.Quantity); pointEnd < end {
+		end = pointEnd
+	}
+	if !block.Table.isBit() {
+		copy(pointData[2*(start-int(point.Address)):], blockData[2*(start-int(block.Address)):2*(end-int(block.Address))])
+		return
+	}
+	for address := start; address < end; address++ {
+		i := address - int(block.Address)
+		if blockData[i/8]>>(i%8)&1 != 0 {
+			j := address - int(point.Address)
+			pointData[j/8] |= 1 << (j % 8)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package modbus
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlannerPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		planner Planner
+		points  []Point
+		blocks  []Block
+	}{
+		{
+			name:    "register limit",
+			planner: Planner{},
+			points:  []Point{{TableHoldingRegisters, 0, 300}},
+			blocks: []Block{
+				{TableHoldingRegisters, 0, 125},
+				{TableHoldingRegisters, 125, 125},
+				{TableHoldingRegisters, 250, 50},
+			},
+		},
+		{
+			name:    "coil limit",
+			planner: Planner{},
+			points:  []Point{{TableCoils, 100, 2100}},
+			blocks:  []Block{{TableCoils, 100, 2000}, {TableCoils, 2100, 100}},
+		},
+		{
+			name:    "smaller register limit",
+			planner: Planner{MaxRegisters: 10},
+			points:  []Point{{TableInputRegisters, 0, 8}, {TableInputRegisters, 8, 8}},
+			blocks:  []Block{{TableInputRegisters, 0, 8}, {TableInputRegisters, 8, 8}},
+		},
+		{
+			name:    "adjacent",
+			planner: Planner{},
+			points:  []Point{{TableHoldingRegisters, 10, 10}, {TableHoldingRegisters, 0, 10}},
+			blocks:  []Block{{TableHoldingRegisters, 0, 20}},
+		},
+		{
+			name:    "overlapping",
+			planner: Planner{},
+			points:  []Point{{TableHoldingRegisters, 0, 10}, {TableHoldingRegisters, 5, 2}, {TableHoldingRegisters, 8, 4}},
+			blocks:  []Block{{TableHoldingRegisters, 0, 12}},
+		},
+		{
+			name:    "gap within max gap",
+			planner: Planner{MaxGap: 4},
+			points:  []Point{{TableHoldingRegisters, 0, 10}, {TableHoldingRegisters, 14, 6}},
+			blocks:  []Block{{TableHoldingRegisters, 0, 20}},
+		},
+		{
+			name:    "gap over max gap",
+			planner: Planner{MaxGap: 4},
+			points:  []Point{{TableHoldingRegisters, 0, 10}, {TableHoldingRegisters, 15, 5}},
+			blocks:  []Block{{TableHoldingRegisters, 0, 10}, {TableHoldingRegisters, 15, 5}},
+		},
+		{
+			name:    "merge up to the limit",
+			planner: Planner{},
+			points:  []Point{{TableHoldingRegisters, 0, 100}, {TableHoldingRegisters, 100, 25}, {TableHoldingRegisters, 125, 1}},
+			blocks:  []Block{{TableHoldingRegisters, 0, 125}, {TableHoldingRegisters, 125, 1}},
+		},
+		{
+			name:    "tables",
+			planner: Planner{MaxGap: 100},
+			points:  []Point{{TableInputRegisters, 0, 1}, {TableCoils, 0, 1}, {TableHoldingRegisters, 0, 1}, {TableDiscreteInputs, 0, 1}},
+			blocks: []Block{
+				{TableCoils, 0, 1},
+				{TableDiscreteInputs, 0, 1},
+				{TableHoldingRegisters, 0, 1},
+				{TableInputRegisters, 0, 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := test.planner.Plan(test.points)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(plan.Blocks, test.blocks) {
+				t.Fatalf("blocks %v, expected %v", plan.Blocks, test.blocks)
+			}
+		})
+	}
+}
+
+func TestPlannerPlanInvalid(t *testing.T) {
+	var planner Planner
+	for _, points := range [][]Point{
+		{{Table(0), 0, 1}},
+		{{TableHoldingRegisters, 0, 0}},
+		{{TableHoldingRegisters, 0xFFFF, 2}},
+	} {
+		if _, err := planner.Plan(points); err == nil {
+			t.Fatalf("plan of %v must fail", points)
+		}
+	}
+}
+
+func TestPlanExecute(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := NewMemoryHandler(2200, 0, 300, 0)
+	for i := range memory.HoldingRegisters {
+		memory.HoldingRegisters[i] = uint16(i)
+	}
+	for i := range memory.Coils {
+		memory.Coils[i] = i%3 == 0
+	}
+	server := NewServer("", memory)
+	go server.Serve(l)
+	defer server.Close()
+
+	handler := NewTCPClientHandler(l.Addr().String())
+	handler.Timeout = 5 * time.Second
+	handler.SlaveId = 1
+	defer handler.Close()
+
+	points := []Point{
+		{TableHoldingRegisters, 120, 10},
+		{TableHoldingRegisters, 2, 2},
+		{TableCoils, 1998, 5},
+		{TableHoldingRegisters, 3, 3},
+		// Split at the register limit, overlapping the first point
+		{TableHoldingRegisters, 100, 150},
+	}
+	planner := Planner{MaxGap: 8}
+	plan, err := planner.Plan(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := plan.Execute(NewClient(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, point := range points {
+		var expected []byte
+		if point.Table == TableCoils {
+			expected = make([]byte, (int(point.Quantity)+7)/8)
+			for j := 0; j < int(point.Quantity); j++ {
+				if memory.Coils[int(point.Address)+j] {
+					expected[j/8] |= 1 << (j % 8)
+				}
+			}
+		} else {
+			for j := 0; j < int(point.Quantity); j++ {
+				v := memory.HoldingRegisters[int(point.Address)+j]
+				expected = append(expected, byte(v>>8), byte(v))
+			}
+		}
+		if !bytes.Equal(results[i], expected) {
+			t.Fatalf("point %v: % x, expected % x", point, results[i], expected)
+		}
+	}
+}
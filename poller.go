@@ -0,0 +1,217 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PollGroup is a set of points read together at a fixed interval.
+type PollGroup struct {
+	Name   string
+	Points []Point
+	// Interval between the scheduled times of two polls
+	Interval time.Duration
+	// Groups due at the same time are polled by decreasing priority, then
+	// by scheduled time.
+	Priority int
+	// Planner merging the points into requests
+	Planner Planner
+
+	// Callback, if set, is called with each result by the goroutine running
+	// the poller, delaying the polls until it returns.
+	Callback func(result PollResult)
+	// Results, if set, receives each result. A result is dropped if the
+	// channel is not ready to receive it, so that the bus is not held up.
+	Results chan<- PollResult
+}
+
+// PollResult is the outcome of a poll of a group.
+type PollResult struct {
+	Group *PollGroup
+	// Data of each point of the group, as returned by Plan.Execute
+	Data [][]byte
+	Err  error
+	// Scheduled is the time the poll was due, Started the time the first
+	// request was sent and Time the time the last response was received.
+	Scheduled time.Time
+	Started   time.Time
+	Time      time.Time
+	// Overrun reports whether the poll took longer than the interval.
+	Overrun bool
+	// Missed is the number of polls skipped after this one because their
+	// scheduled time passed while the bus was busy.
+	Missed int
+}
+
+// pollState is a group added to a poller.
+type pollState struct {
+	group *PollGroup
+	plan  *Plan
+	next  time.Time
+}
+
+// Poller polls groups of points at their interval with a single goroutine,
+// so that a single request is pending on the bus at a time.
+type Poller struct {
+	Client ClientContext
+	// Poll logger
+	Logger Logger
+
+	mu     sync.Mutex
+	states []*pollState
+	wake   chan struct{}
+}
+
+// NewPoller allocates a poller sending requests with handler.
+func NewPoller(handler ClientHandler) *Poller {
+	return &Poller{
+		Client: NewClient(handler),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Add plans the points of group and schedules its first poll immediately.
+// The group must not be modified until it is removed.
+func (p *Poller) Add(group *PollGroup) (err error) {
+	if group.Interval <= 0 {
+		err = fmt.Errorf("modbus: interval '%v' of poll group '%v' must be positive", group.Interval, group.Name)
+		return
+	}
+	plan, err := group.Planner.Plan(group.Points)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	p.states = append(p.states, &pollState{group: group, plan: plan, next: time.Now()})
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return
+}
+
+// Remove stops polling group. A poll in progress still delivers its result.
+func (p *Poller) Remove(group *PollGroup) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, s := range p.states {
+		if s.group == group {
+			p.states = append(p.states[:i], p.states[i+1:]...)
+			return
+		}
+	}
+}
+
+// Run polls the groups until ctx is done and returns the context error.
+// It must not be called concurrently.
+func (p *Poller) Run(ctx context.Context) error {
+	for {
+		due, wait := p.due(time.Now())
+		if due != nil {
+			p.poll(ctx, due)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-p.wake:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// due returns the group to poll at now, or the time to wait for the next
+// group to be due, negative if there is no group.
+func (p *Poller) due(now time.Time) (due *pollState, wait time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	wait = -1
+	for _, s := range p.states {
+		if s.next.After(now) {
+			if d := s.next.Sub(now); wait < 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		if due == nil || s.group.Priority > due.group.Priority ||
+			(s.group.Priority == due.group.Priority && s.next.Before(due.next)) {
+			due = s
+		}
+	}
+	return
+}
+
+// poll reads the group, schedules its next poll and delivers the result.
+func (p *Poller) poll(ctx context.Context, s *pollState) {
+	scheduled := s.next
+	interval := s.group.Interval
+	started := time.Now()
+	data, err := s.plan.ExecuteContext(ctx, p.Client)
+	finished := time.Now()
+	if ctx.Err() != nil {
+		return
+	}
+	// Skip the scheduled times which passed while polling
+	missed := 0
+	next := scheduled.Add(interval)
+	if !next.After(finished) {
+		missed = int(finished.Sub(scheduled) / interval)
+		next = scheduled.Add(time.Duration(missed+1) * interval)
+	}
+	p.mu.Lock()
+	s.next = next
+	p.mu.Unlock()
+
+	result := PollResult{
+		Group:     s.group,
+		Data:      data,
+		Err:       err,
+		Scheduled: scheduled,
+		Started:   started,
+		Time:      finished,
+		Overrun:   finished.Sub(started) > interval,
+		Missed:    missed,
+	}
+	if err != nil {
+		p.Debugf("modbus: polling group '%v' failed: %v", s.group.Name, err)
+	}
+	if missed > 0 {
+		p.Debugf("modbus: poll group '%v' missed %v polls, started %v late", s.group.Name, missed, started.Sub(scheduled))
+	}
+	if s.group.Callback != nil {
+		s.group.Callback(result)
+	}
+	if s.group.Results != nil {
+		select {
+		case s.group.Results <- result:
+		default:
+			p.Debugf("modbus: dropping result of poll group '%v'", s.group.Name)
+		}
+	}
+}
+
+func (p *Poller) Debugf(format string, v ...interface{}) {
+	if p.Logger != nil {
+		p.Logger.Debugf(format, v...)
+	}
+}
@@ -0,0 +1,153 @@
+package modbus
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// Default number of connections of a pool
+const pooledSize = 4
+
+// PooledTCPClientHandler implements Packager and Transporter interface.
+// Requests sent concurrently are spread over several connections to the
+// same server, one request being pending per connection at a time.
+type PooledTCPClientHandler struct {
+	TcpPackager
+	pooledTcpTransporter
+}
+
+// NewPooledTCPClientHandler allocates a new PooledTCPClientHandler of up
+// to size connections.
+func NewPooledTCPClientHandler(address string, size int) *PooledTCPClientHandler {
+	h := &PooledTCPClientHandler{}
+	h.Address = address
+	h.Timeout = tcpTimeout
+	h.IdleTimeout = tcpIdleTimeout
+	h.Size = size
+	return h
+}
+
+// pooledTcpTransporter implements Transporter interface. The settings are
+// copied to each connection of the pool and must be set before sending.
+type pooledTcpTransporter struct {
+	// Connect string
+	Address string
+	// Connect & Read timeout
+	Timeout time.Duration
+	// Idle timeout to close each connection
+	IdleTimeout time.Duration
+	// Query delay duration of each connection
+	QueryDelayDuration time.Duration
+	// Transmission logger
+	Logger Logger
+	// TLS configuration, the connections are secured with TLS if set
+	TLSConfig *tls.Config
+	// Maximum number of connections
+	Size int
+
+	initOnce sync.Once
+	// Requests in progress, bounded by Size
+	slots chan struct{}
+	mu    sync.Mutex
+	ports []*tcpTransporter
+	// Stack of the ports not in use, most recently used last
+	free []*tcpTransporter
+}
+
+func (mb *pooledTcpTransporter) init() {
+	size := mb.Size
+	if size <= 0 {
+		size = pooledSize
+	}
+	mb.slots = make(chan struct{}, size)
+	mb.ports = make([]*tcpTransporter, size)
+	mb.free = make([]*tcpTransporter, size)
+	for i := range mb.ports {
+		port := &tcpTransporter{}
+		port.Address = mb.Address
+		port.Timeout = mb.Timeout
+		port.IdleTimeout = mb.IdleTimeout
+		port.QueryDelayDuration = mb.QueryDelayDuration
+		port.Logger = mb.Logger
+		port.TLSConfig = mb.TLSConfig
+		mb.ports[i] = port
+		mb.free[i] = port
+	}
+}
+
+// Send sends the request on a connection not in use, waiting for one if
+// all are busy. A connection failing, or receiving the response to another
+// request, is closed and dialed again by the next request using it.
+func (mb *pooledTcpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done.
+func (mb *pooledTcpTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	mb.initOnce.Do(mb.init)
+	select {
+	case mb.slots <- struct{}{}:
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+	defer func() { <-mb.slots }()
+
+	// The most recently used port is likely connected, the others are left
+	// idle so that their connections are closed when the load is light.
+	mb.mu.Lock()
+	port := mb.free[len(mb.free)-1]
+	mb.free = mb.free[:len(mb.free)-1]
+	mb.mu.Unlock()
+	defer func() {
+		mb.mu.Lock()
+		mb.free = append(mb.free, port)
+		mb.mu.Unlock()
+	}()
+
+	aduResponse, err = port.SendContext(ctx, aduRequest)
+	if err != nil || (aduResponse != nil && !tcpResponseMatches(aduRequest, aduResponse)) {
+		// A late response would otherwise answer the next request
+		port.Mu.Lock()
+		_ = port.ConnClose()
+		port.Mu.Unlock()
+	}
+	return
+}
+
+// toleratesMismatch keeps the other connections open when a response fails
+// verification: SendContext already closed the connection it was read on,
+// closing the pool would abort the requests in progress on the others.
+func (mb *pooledTcpTransporter) toleratesMismatch() bool {
+	return true
+}
+
+// Connect connects the first connection of the pool, the others are
+// connected on demand.
+func (mb *pooledTcpTransporter) Connect() error {
+	mb.initOnce.Do(mb.init)
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if len(mb.free) == 0 {
+		return nil
+	}
+	port := mb.free[len(mb.free)-1]
+	port.Mu.Lock()
+	defer port.Mu.Unlock()
+	return port.Connect()
+}
+
+// Close closes all connections of the pool, waiting for the requests in
+// progress.
+func (mb *pooledTcpTransporter) Close() (err error) {
+	mb.initOnce.Do(mb.init)
+	for _, port := range mb.ports {
+		if closeErr := port.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
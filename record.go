@@ -0,0 +1,235 @@
+package modbus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Exchange is a request and its response recorded by a RecordingTransporter.
+type Exchange struct {
+	// Time the request was sent
+	Time time.Time `json:"time"`
+	// Time to receive the response, in nanoseconds in JSON
+	Duration time.Duration `json:"duration"`
+	Request  HexBytes      `json:"request"`
+	// Response, nil if the request was not answered
+	Response HexBytes `json:"response,omitempty"`
+	// Error of the transporter, if any
+	Error string `json:"error,omitempty"`
+	// Timeout reports whether the error was a timeout.
+	Timeout bool `json:"timeout,omitempty"`
+}
+
+// RecordingTransporter implements Transporter interface, sending the
+// requests with Transporter and writing each exchange to Output as a line
+// of JSON, which ReplayTransporter reads.
+type RecordingTransporter struct {
+	Transporter Transporter
+	Output      io.Writer
+
+	mu sync.Mutex
+}
+
+// NewRecordingTransporter allocates a RecordingTransporter.
+func NewRecordingTransporter(transporter Transporter, output io.Writer) *RecordingTransporter {
+	return &RecordingTransporter{
+		Transporter: transporter,
+		Output:      output,
+	}
+}
+
+func (mb *RecordingTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done, if
+// the transporter supports it. A failure to write the exchange is returned
+// when the transaction succeeded.
+func (mb *RecordingTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	exchange := Exchange{
+		Time:    time.Now(),
+		Request: append(HexBytes(nil), aduRequest...),
+	}
+	if transporter, ok := mb.Transporter.(TransporterContext); ok {
+		aduResponse, err = transporter.SendContext(ctx, aduRequest)
+	} else if err = ctx.Err(); err == nil {
+		aduResponse, err = mb.Transporter.Send(aduRequest)
+	}
+	exchange.Duration = time.Since(exchange.Time)
+	if aduResponse != nil {
+		exchange.Response = append(HexBytes(nil), aduResponse...)
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+		exchange.Timeout = isTimeout(err)
+	}
+	if recordErr := mb.record(&exchange); recordErr != nil && err == nil {
+		err = recordErr
+	}
+	return
+}
+
+func (mb *RecordingTransporter) Close() error {
+	return mb.Transporter.Close()
+}
+
+// toleratesMismatch forwards whether Transporter keeps its connection open
+// when a response fails verification.
+func (mb *RecordingTransporter) toleratesMismatch() bool {
+	transporter, ok := mb.Transporter.(mismatchTolerant)
+	return ok && transporter.toleratesMismatch()
+}
+
+func (mb *RecordingTransporter) record(exchange *Exchange) error {
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return err
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	_, err = mb.Output.Write(append(line, '\n'))
+	return err
+}
+
+// replayError is a recorded error. A recorded timeout implements net.Error
+// so that it is classified as a timeout again.
+type replayError struct {
+	message string
+	timeout bool
+}
+
+func (e *replayError) Error() string   { return e.message }
+func (e *replayError) Timeout() bool   { return e.timeout }
+func (e *replayError) Temporary() bool { return e.timeout }
+
+// ReplayTransporter implements Transporter interface, answering the
+// requests with the responses of the matching exchanges recorded by a
+// RecordingTransporter, without any device. Each exchange answers a single
+// request, in the recorded order among the exchanges of the same request.
+type ReplayTransporter struct {
+	// IgnoreTransactionId matches Modbus TCP requests regardless of their
+	// MBAP transaction id, the responses take the transaction id of the
+	// request.
+	IgnoreTransactionId bool
+	// Reuse answers a request with the last matching exchange once all are
+	// used, e.g. for polling loops longer than the recording.
+	Reuse bool
+	// Realtime waits for the recorded duration before answering.
+	Realtime bool
+
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+}
+
+// NewReplayTransporter allocates a ReplayTransporter answering with the
+// exchanges read from r.
+func NewReplayTransporter(r io.Reader) (*ReplayTransporter, error) {
+	mb := &ReplayTransporter{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("modbus: exchange on line '%v' is invalid: %v", line, err)
+		}
+		mb.exchanges = append(mb.exchanges, exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	mb.used = make([]bool, len(mb.exchanges))
+	return mb, nil
+}
+
+func (mb *ReplayTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which returns when ctx is done while waiting for the
+// recorded duration.
+func (mb *ReplayTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	exchange, ok := mb.match(aduRequest)
+	if !ok {
+		err = fmt.Errorf("modbus: no recorded exchange matches request '% x'", aduRequest)
+		return
+	}
+	if mb.Realtime {
+		if err = sleepContext(ctx, exchange.Duration); err != nil {
+			return
+		}
+	}
+	if exchange.Error != "" {
+		err = &replayError{message: exchange.Error, timeout: exchange.Timeout}
+		return
+	}
+	if exchange.Response == nil {
+		return
+	}
+	aduResponse = append([]byte(nil), exchange.Response...)
+	if mb.IgnoreTransactionId && len(aduResponse) >= 2 {
+		copy(aduResponse, aduRequest[:2])
+	}
+	return
+}
+
+func (mb *ReplayTransporter) Close() error {
+	return nil
+}
+
+// Remaining returns the number of exchanges which have not answered a
+// request, so that a test can check the whole recording was replayed.
+func (mb *ReplayTransporter) Remaining() (n int) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	for _, used := range mb.used {
+		if !used {
+			n++
+		}
+	}
+	return
+}
+
+// match returns the first unused exchange of the request, or the last
+// matching one with Reuse.
+func (mb *ReplayTransporter) match(aduRequest []byte) (exchange Exchange, ok bool) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	last := -1
+	for i := range mb.exchanges {
+		if !mb.matches(mb.exchanges[i].Request, aduRequest) {
+			continue
+		}
+		if !mb.used[i] {
+			mb.used[i] = true
+			return mb.exchanges[i], true
+		}
+		last = i
+	}
+	if mb.Reuse && last >= 0 {
+		return mb.exchanges[last], true
+	}
+	return
+}
+
+func (mb *ReplayTransporter) matches(recorded, aduRequest []byte) bool {
+	if mb.IgnoreTransactionId && len(recorded) >= 2 && len(aduRequest) >= 2 {
+		return bytes.Equal(recorded[2:], aduRequest[2:])
+	}
+	return bytes.Equal(recorded, aduRequest)
+}
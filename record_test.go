@@ -0,0 +1,66 @@
+package modbus
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer("", NewMemoryHandler(8, 0, 8, 0))
+	go server.Serve(l)
+	defer server.Close()
+
+	handler := NewTCPClientHandler(l.Addr().String())
+	handler.Timeout = 5 * time.Second
+	handler.SlaveId = 1
+	defer handler.Close()
+	var recording bytes.Buffer
+	recorder := NewRecordingTransporter(handler, &recording)
+	exchange := func(client Client) []byte {
+		if _, err := client.WriteMultipleRegisters(2, 2, []byte{0, 1, 0, 2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.WriteSingleCoil(3, 0xFF00); err != nil {
+			t.Fatal(err)
+		}
+		results, err := client.ReadHoldingRegisters(2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+	recorded := exchange(NewClient2(&handler.TcpPackager, recorder))
+
+	replay, err := NewReplayTransporter(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay.IgnoreTransactionId = true
+	// The transaction ids differ from the recording
+	packager := &TcpPackager{SlaveId: 1, transactionId: 100}
+	replayed := exchange(NewClient2(packager, replay))
+	if !bytes.Equal(recorded, []byte{0, 1, 0, 2}) || !bytes.Equal(replayed, recorded) {
+		t.Fatalf("recorded % x, replayed % x", recorded, replayed)
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Fatalf("'%v' exchanges not replayed", n)
+	}
+	if _, err = NewClient2(packager, replay).ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("request not recorded must fail")
+	}
+}
+
+func TestRecordingTransporterToleratesMismatch(t *testing.T) {
+	if NewRecordingTransporter(NewTCPClientHandler(""), nil).toleratesMismatch() {
+		t.Fatal("TCP connection must be closed on a mismatched response")
+	}
+	if !NewRecordingTransporter(NewPipelinedTCPClientHandler(""), nil).toleratesMismatch() {
+		t.Fatal("pipelined connection must be kept open on a mismatched response")
+	}
+}
@@ -0,0 +1,53 @@
+package modbus
+
+import (
+	"sync"
+)
+
+// ResponseLengthFunc returns the expected length of the response PDU
+// (function code and data) to the request PDU. For responses whose length
+// depends on their content, it is called again as the response arrives:
+// received holds the part of the response PDU read so far, possibly
+// followed by the checksum, and is empty on the first call.
+type ResponseLengthFunc func(request, received []byte) int
+
+var responseLengths struct {
+	sync.RWMutex
+	rules map[byte]ResponseLengthFunc
+}
+
+// RegisterResponseLength sets the rule used by the RTU transporters to
+// know how many bytes to read in response to functionCode, typically a
+// user defined or vendor specific code. It takes precedence over the
+// built-in rules; a nil rule removes the registration.
+func RegisterResponseLength(functionCode byte, rule ResponseLengthFunc) {
+	responseLengths.Lock()
+	defer responseLengths.Unlock()
+
+	if rule == nil {
+		delete(responseLengths.rules, functionCode)
+		return
+	}
+	if responseLengths.rules == nil {
+		responseLengths.rules = make(map[byte]ResponseLengthFunc)
+	}
+	responseLengths.rules[functionCode] = rule
+}
+
+// customResponseLength applies the rule registered for the function code
+// of the RTU request, if any, and returns the expected RTU frame length.
+func customResponseLength(aduRequest, aduResponse []byte) (length int, ok bool) {
+	responseLengths.RLock()
+	rule, ok := responseLengths.rules[aduRequest[1]]
+	responseLengths.RUnlock()
+	if !ok {
+		return
+	}
+	var received []byte
+	if len(aduResponse) > 1 {
+		received = aduResponse[1:]
+	}
+	// Slave address, PDU and CRC
+	length = 1 + rule(aduRequest[1:len(aduRequest)-2], received) + 2
+	return
+}
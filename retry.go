@@ -0,0 +1,170 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/jifanchn/serial"
+)
+
+const (
+	retryMaxAttempts    = 3
+	retryInitialBackoff = 100 * time.Millisecond
+	retryMaxBackoff     = 5 * time.Second
+	retryMultiplier     = 2
+)
+
+// RetryPolicy controls how a client sends a request again after a failure.
+// Zero fields but Jitter take their default value.
+type RetryPolicy struct {
+	// Maximum number of attempts of a request, including the first one
+	MaxAttempts int
+	// Delay before the first retry, multiplied by Multiplier after each
+	// retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Fraction of the delay randomly added or removed, between 0 and 1, so
+	// that clients sharing a device do not retry in lockstep
+	Jitter float64
+	// RetryNonIdempotent allows retrying requests which change the state of
+	// the device, such as writes, which may have been executed although the
+	// response was lost. Only reads are retried by default.
+	RetryNonIdempotent bool
+	// Retryable, if set, replaces IsRetryable to classify errors.
+	Retryable func(err error) bool
+}
+
+// WithRetryPolicy makes the client retry failed requests as described by
+// policy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(mb *client) {
+		mb.retry = &policy
+	}
+}
+
+// IsRetryable reports whether err may not happen again when the request is
+// sent again: timeouts, checksum mismatches, and the server device busy and
+// gateway exceptions.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var mbError *ModbusError
+	if errors.As(err, &mbError) {
+		switch mbError.ExceptionCode {
+		case ExceptionCodeServerDeviceBusy,
+			ExceptionCodeGatewayPathUnavailable,
+			ExceptionCodeGatewayTargetDeviceFailedToRespond:
+			return true
+		}
+		return false
+	}
+	var checksumError *ChecksumError
+	return errors.As(err, &checksumError) || isTimeout(err)
+}
+
+// isTimeout reports whether err is a timeout of the transporter.
+func isTimeout(err error) bool {
+	if errors.Is(err, serial.ErrTimeout) {
+		return true
+	}
+	var netError net.Error
+	return errors.As(err, &netError) && netError.Timeout()
+}
+
+// shouldRetry reports whether request is sent again after attempt failed
+// with err.
+func (policy *RetryPolicy) shouldRetry(request *ProtocolDataUnit, attempt int, err error) bool {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = retryMaxAttempts
+	}
+	if attempt >= maxAttempts {
+		return false
+	}
+	if !policy.RetryNonIdempotent && !idempotent(request.FunctionCode) {
+		return false
+	}
+	if policy.Retryable != nil {
+		return policy.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff returns the delay before sending a request again after attempt.
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(policy.delay(attempt))
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// delay returns the delay after attempt, without jitter.
+func (policy *RetryPolicy) delay(attempt int) time.Duration {
+	delay := float64(policy.InitialBackoff)
+	if delay <= 0 {
+		delay = float64(retryInitialBackoff)
+	}
+	maxDelay := float64(policy.MaxBackoff)
+	if maxDelay <= 0 {
+		maxDelay = float64(retryMaxBackoff)
+	}
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = retryMultiplier
+	}
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= multiplier
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return time.Duration(delay)
+}
+
+// maxDuration returns the longest time the request may take when each
+// attempt takes up to timeout, including the delays between attempts. The
+// policy may be nil, the request being sent once.
+func (policy *RetryPolicy) maxDuration(request *ProtocolDataUnit, timeout time.Duration) time.Duration {
+	if policy == nil || (!policy.RetryNonIdempotent && !idempotent(request.FunctionCode)) {
+		return timeout
+	}
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = retryMaxAttempts
+	}
+	duration := timeout
+	for attempt := 1; attempt < maxAttempts; attempt++ {
+		delay := policy.delay(attempt)
+		if policy.Jitter > 0 {
+			delay += time.Duration(float64(delay) * policy.Jitter)
+		}
+		duration += delay + timeout
+	}
+	return duration
+}
+
+// idempotent reports whether a request of the function leaves the device
+// in the same state when executed several times.
+func idempotent(functionCode byte) bool {
+	switch functionCode {
+	case FuncCodeReadCoils,
+		FuncCodeReadDiscreteInputs,
+		FuncCodeReadHoldingRegisters,
+		FuncCodeReadInputRegisters,
+		FuncCodeReadExceptionStatus,
+		FuncCodeGetCommEventCounter,
+		FuncCodeGetCommEventLog,
+		FuncCodeReportServerId,
+		FuncCodeReadFileRecord,
+		FuncCodeEncapsulatedInterfaceTransport:
+		return true
+	}
+	// Writes, diagnostics and FIFO reads, which pop the queue
+	return false
+}
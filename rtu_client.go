@@ -5,10 +5,14 @@
 package modbus
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sync"
 	"time"
+
+	"github.com/jifanchn/serial"
 )
 
 const (
@@ -30,6 +34,7 @@ func NewRTUClientHandler(address string) *RTUClientHandler {
 	handler.Address = address
 	handler.Timeout = serialTimeout
 	handler.IdleTimeout = serialIdleTimeout
+	handler.TurnaroundDelay = serialTurnaroundDelay
 	return handler
 }
 
@@ -39,6 +44,14 @@ type RtuPackager struct {
 	SlaveId byte
 }
 
+func (mb *RtuPackager) slaveId() byte {
+	return mb.SlaveId
+}
+
+func (mb *RtuPackager) broadcasts() bool {
+	return true
+}
+
 // Encode encodes PDU in a RTU frame:
 //
 //	Slave Address   : 1 byte
@@ -46,6 +59,11 @@ type RtuPackager struct {
 //	Data            : 0 up to 252 bytes
 //	CRC             : 2 byte
 func (mb *RtuPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
+	return mb.encodeSlave(pdu, mb.SlaveId)
+}
+
+// encodeSlave is Encode addressing the PDU to slaveId.
+func (mb *RtuPackager) encodeSlave(pdu *ProtocolDataUnit, slaveId byte) (adu []byte, err error) {
 	length := len(pdu.Data) + 4
 	if length > rtuMaxSize {
 		err = fmt.Errorf("modbus: length of data '%v' must not be bigger than '%v'", length, rtuMaxSize)
@@ -53,7 +71,7 @@ func (mb *RtuPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
 	}
 	adu = make([]byte, length)
 
-	adu[0] = mb.SlaveId
+	adu[0] = slaveId
 	adu[1] = pdu.FunctionCode
 	copy(adu[2:], pdu.Data)
 
@@ -91,7 +109,7 @@ func (mb *RtuPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	crc.reset().pushBytes(adu[0 : length-2])
 	checksum := uint16(adu[length-1])<<8 | uint16(adu[length-2])
 	if checksum != crc.value() {
-		err = fmt.Errorf("modbus: response crc '%v' does not match expected '%v'", checksum, crc.value())
+		err = &ChecksumError{Name: "crc", Received: checksum, Expected: crc.value()}
 		return
 	}
 	// Function code & data
@@ -104,9 +122,35 @@ func (mb *RtuPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 // rtuSerialTransporter implements Transporter interface.
 type rtuSerialTransporter struct {
 	SerialPort
+	// SilenceFraming receives responses up to the inter-frame silence
+	// instead of predicting their length from the request, so that the
+	// responses of any function are received. It must be set before sending.
+	SilenceFraming bool
+	// FrameSilence overrides the 3.5 character silence ending a frame, for
+	// USB adapters which deliver the characters in batches.
+	FrameSilence time.Duration
+	// Resync recovers from line noise: the input received before a request
+	// is discarded, and the response is searched in the received characters
+	// as a frame of the slave and function of the request whose CRC is
+	// valid. It must be set before sending.
+	Resync bool
+
+	// Frame reader of the connection framesConn, when SilenceFraming or
+	// Resync is set
+	frames     *rtuFrameReader
+	framesConn io.ReadWriteCloser
+	// Number of characters discarded as noise, guarded by noiseMu rather
+	// than Mu, which is held during transactions
+	noiseMu    sync.Mutex
+	noiseBytes uint64
 }
 
 func (mb *rtuSerialTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done.
+func (mb *rtuSerialTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	mb.Mu.Lock()
 	defer func() {
 		if mb.QueryDelayDuration > 0 {
@@ -114,78 +158,306 @@ func (mb *rtuSerialTransporter) Send(aduRequest []byte) (aduResponse []byte, err
 		}
 		mb.Mu.Unlock()
 	}()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
 	// Make sure port is connected
-	if err = mb.Connect(); err != nil {
+	if err = mb.ConnectContext(ctx); err != nil {
 		return
 	}
 	// Start the timer to close when idle
 	mb.LastActivity = time.Now()
 	mb.StartCloseTimer()
 
+	// The serial port has no deadline, closing it aborts pending I/O when ctx is done
+	conn := mb.Conn
+	stop := watchContext(ctx, func() { _ = conn.Close() })
+	defer func() {
+		if interrupted := stop(); interrupted || (err != nil && ctx.Err() != nil) {
+			_ = mb.ConnClose()
+			if err != nil {
+				err = ctx.Err()
+			}
+		}
+	}()
+
+	var frames *rtuFrameReader
+	if mb.SilenceFraming || mb.Resync {
+		frames = mb.frameReader()
+		mb.discard(frames.drain(nil))
+	}
+
 	// Send the request
 	mb.Debugf("modbus: sending % x", aduRequest)
 	if _, err = mb.Conn.Write(aduRequest); err != nil {
 		_ = mb.ConnClose()
 		return
 	}
-	function := aduRequest[1]
-	functionFail := aduRequest[1] & 0x80
 	bytesToRead := calculateResponseLength(aduRequest)
-	time.Sleep(mb.calculateDelay(len(aduRequest) + bytesToRead))
-
-	var n int
-	var n1 int
-	var data [rtuMaxSize]byte
-	//We first read the minimum length and then read either the full package
-	//or the error package, depending on the error status (byte 2 of the response)
-	n, err = io.ReadAtLeast(mb.Conn, data[:], rtuMinSize)
+	if frames == nil || bytesToRead == 0 {
+		if err = sleepContext(ctx, mb.calculateDelay(len(aduRequest)+bytesToRead)); err != nil {
+			return
+		}
+	}
+	if bytesToRead == 0 {
+		if aduRequest[0] == 0 {
+			err = sleepContext(ctx, mb.TurnaroundDelay)
+		}
+		return
+	}
+	if frames != nil {
+		aduResponse, err = mb.readFramedResponse(frames, aduRequest)
+	} else {
+		aduResponse, err = readRTUResponse(mb.Conn, aduRequest)
+	}
 	if err != nil {
 		return
 	}
-	//if the function is correct
-	if data[1] == function {
-		//we read the rest of the bytes
-		if n < bytesToRead {
-			if bytesToRead > rtuMinSize && bytesToRead <= rtuMaxSize {
-				if bytesToRead > n {
-					n1, err = io.ReadFull(mb.Conn, data[n:bytesToRead])
-					n += n1
-				}
+	mb.Debugf("modbus: received % x", aduResponse)
+	return
+}
+
+// NoiseBytes returns the number of characters discarded by Resync, which
+// were received outside of the responses.
+func (mb *rtuSerialTransporter) NoiseBytes() uint64 {
+	mb.noiseMu.Lock()
+	defer mb.noiseMu.Unlock()
+
+	return mb.noiseBytes
+}
+
+// discard counts the noise characters.
+func (mb *rtuSerialTransporter) discard(noise []byte) {
+	if len(noise) > 0 {
+		mb.noiseMu.Lock()
+		mb.noiseBytes += uint64(len(noise))
+		mb.noiseMu.Unlock()
+		mb.Debugf("modbus: discarding % x", noise)
+	}
+}
+
+// frameReader returns the frame reader of the connection, starting one if
+// the connection changed. Caller must hold the mutex.
+func (mb *rtuSerialTransporter) frameReader() *rtuFrameReader {
+	if mb.frames != nil && mb.framesConn == mb.Conn {
+		select {
+		case <-mb.frames.done:
+		default:
+			return mb.frames
+		}
+	}
+	if mb.frames != nil {
+		mb.frames.close()
+	}
+	mb.frames = newRTUFrameReader(mb.Conn)
+	mb.framesConn = mb.Conn
+	return mb.frames
+}
+
+// readFramedResponse reads the response delimited by the inter-frame
+// silence. A frame failing the CRC check is completed with the next
+// characters, as the silence may have been a gap inside the frame. With
+// Resync, the characters around the response are discarded.
+func (mb *rtuSerialTransporter) readFramedResponse(frames *rtuFrameReader, aduRequest []byte) (aduResponse []byte, err error) {
+	silence := mb.FrameSilence
+	if silence <= 0 {
+		silence = rtuDelay(mb.BaudRate, 0)
+	}
+	timeout := mb.Timeout
+	if timeout <= 0 {
+		timeout = serialTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var data []byte
+	for {
+		var frame []byte
+		if frame, err = frames.readFrame(silence, timer.C); err != nil {
+			if err == serial.ErrTimeout && len(data) > 0 {
+				// Incomplete, the packager reports the mismatch
+				aduResponse, err = data, nil
 			}
+			return
 		}
-	} else if data[1] == functionFail {
-		//for error we need to read 5 bytes
-		if n < rtuExceptionSize {
-			n1, err = io.ReadFull(mb.Conn, data[n:rtuExceptionSize])
+		data = append(data, frame...)
+		if !mb.Resync {
+			if rtuChecksumValid(data) || len(data) >= rtuMaxSize {
+				aduResponse = data
+				return
+			}
+			continue
+		}
+		if start, end, ok := findRTUResponse(aduRequest, data, !mb.SilenceFraming); ok {
+			mb.discard(data[:start])
+			mb.discard(data[end:])
+			aduResponse = data[start:end]
+			return
+		}
+		if len(data) > 2*rtuMaxSize {
+			// Keep the characters which may start the response
+			mb.discard(data[:len(data)-rtuMaxSize])
+			data = append([]byte(nil), data[len(data)-rtuMaxSize:]...)
 		}
-		n += n1
 	}
+}
 
-	if err != nil {
-		return
+// findRTUResponse looks in data for the response to aduRequest, a frame
+// starting with the slave id and the function code of the request, or its
+// exception, with a valid CRC. With predict set, the length of a normal
+// response is predicted from the request, otherwise any length is tried.
+func findRTUResponse(aduRequest, data []byte, predict bool) (start, end int, ok bool) {
+	for start = 0; start+rtuMinSize <= len(data); start++ {
+		if data[start] != aduRequest[0] {
+			continue
+		}
+		switch data[start+1] {
+		case aduRequest[1] | 0x80:
+			end = start + rtuExceptionSize
+			if end <= len(data) && rtuChecksumValid(data[start:end]) {
+				return start, end, true
+			}
+		case aduRequest[1]:
+			if predict {
+				end = start + calculateReceivedLength(aduRequest, data[start:])
+				if end <= len(data) && rtuChecksumValid(data[start:end]) {
+					return start, end, true
+				}
+				continue
+			}
+			for end = start + rtuMinSize; end <= len(data) && end-start <= rtuMaxSize; end++ {
+				if rtuChecksumValid(data[start:end]) {
+					return start, end, true
+				}
+			}
+		}
 	}
-	aduResponse = data[:n]
-	mb.Debugf("modbus: received % x", aduResponse)
-	return
+	return 0, 0, false
+}
+
+// rtuChecksumValid reports whether adu is a frame ending with its CRC.
+func rtuChecksumValid(adu []byte) bool {
+	length := len(adu)
+	if length < rtuMinSize {
+		return false
+	}
+	var crc crc
+	crc.reset().pushBytes(adu[0 : length-2])
+	return uint16(adu[length-1])<<8|uint16(adu[length-2]) == crc.value()
+}
+
+// toleratesMismatch keeps the serial port open when a response fails
+// verification: a response from another slave is a fault of the line,
+// which reopening the port does not cure.
+func (mb *rtuSerialTransporter) toleratesMismatch() bool {
+	return true
 }
 
 // calculateDelay roughly calculates time needed for the next frame.
 // See MODBUS over Serial Line - Specification and Implementation Guide (page 13).
 func (mb *rtuSerialTransporter) calculateDelay(chars int) time.Duration {
+	return rtuDelay(mb.BaudRate, chars)
+}
+
+// rtuDelay returns the time needed to transmit the given number of
+// characters followed by the 3.5 character inter-frame silence.
+func rtuDelay(baudRate, chars int) time.Duration {
 	var characterDelay, frameDelay int // us
 
-	if mb.BaudRate <= 0 || mb.BaudRate > 19200 {
+	if baudRate <= 0 || baudRate > 19200 {
 		characterDelay = 750
 		frameDelay = 1750
 	} else {
-		characterDelay = 15000000 / mb.BaudRate
-		frameDelay = 35000000 / mb.BaudRate
+		characterDelay = 15000000 / baudRate
+		frameDelay = 35000000 / baudRate
 	}
 	return time.Duration(characterDelay*chars+frameDelay) * time.Microsecond
 }
 
+// readRTUResponse reads the response to aduRequest from r. It first reads
+// the minimum frame, then either the rest of the normal response or of
+// the exception response.
+func readRTUResponse(r io.Reader, aduRequest []byte) (aduResponse []byte, err error) {
+	function := aduRequest[1]
+	functionFail := aduRequest[1] | 0x80
+
+	var n int
+	var n1 int
+	var data [rtuMaxSize]byte
+	n, err = io.ReadAtLeast(r, data[:], rtuMinSize)
+	if err != nil {
+		return
+	}
+	if data[1] == function {
+		// Variable length responses announce their size as they go
+		for {
+			bytesToRead := calculateReceivedLength(aduRequest, data[:n])
+			if bytesToRead <= n || bytesToRead > rtuMaxSize {
+				break
+			}
+			n1, err = io.ReadFull(r, data[n:bytesToRead])
+			n += n1
+			if err != nil {
+				return
+			}
+		}
+	} else if data[1] == functionFail {
+		// For error we need to read 5 bytes
+		if n < rtuExceptionSize {
+			n1, err = io.ReadFull(r, data[n:rtuExceptionSize])
+		}
+		n += n1
+		if err != nil {
+			return
+		}
+	}
+	aduResponse = data[:n]
+	return
+}
+
+// calculateReceivedLength refines calculateResponseLength with the part
+// of the response received so far, for responses of variable length.
+func calculateReceivedLength(aduRequest, aduResponse []byte) int {
+	if length, ok := customResponseLength(aduRequest, aduResponse); ok {
+		return length
+	}
+	switch aduRequest[1] {
+	case FuncCodeGetCommEventLog, FuncCodeReportServerId, FuncCodeReadFileRecord:
+		// Slave address, function code, byte count
+		if len(aduResponse) < 3 {
+			return 3 + 2
+		}
+		return 3 + int(aduResponse[2]) + 2
+	case FuncCodeEncapsulatedInterfaceTransport:
+		// Slave address, function code, MEI type, read device id code,
+		// conformity level, more follows, next object id, number of objects
+		length := 8
+		if len(aduResponse) < length {
+			return length + 2
+		}
+		for i := 0; i < int(aduResponse[7]); i++ {
+			// Object id, object length
+			if len(aduResponse) < length+2 {
+				return length + 2 + 2
+			}
+			length += 2 + int(aduResponse[length+1])
+		}
+		// CRC
+		return length + 2
+	}
+	return calculateResponseLength(aduRequest)
+}
+
+// calculateResponseLength returns the expected length of the response to
+// the request adu, or 0 if the request is not answered.
 func calculateResponseLength(adu []byte) int {
+	if adu[0] == 0 {
+		// Broadcast
+		return 0
+	}
+	if length, ok := customResponseLength(adu, nil); ok {
+		return length
+	}
 	length := rtuMinSize
 	switch adu[1] {
 	case FuncCodeReadDiscreteInputs,
@@ -207,6 +479,30 @@ func calculateResponseLength(adu []byte) int {
 		length += 4
 	case FuncCodeMaskWriteRegister:
 		length += 6
+	case FuncCodeReadExceptionStatus:
+		length++
+	case FuncCodeDiagnostics:
+		// The request is echoed, except when entering listen only mode
+		if len(adu) >= 6 && binary.BigEndian.Uint16(adu[2:]) == DiagSubFuncForceListenOnlyMode {
+			return 0
+		}
+		length = len(adu)
+	case FuncCodeGetCommEventCounter:
+		length += 4
+	case FuncCodeGetCommEventLog:
+		// Byte count, status, event count and message count,
+		// see calculateReceivedLength
+		length += 7
+	case FuncCodeReportServerId, FuncCodeReadFileRecord:
+		// Byte count, see calculateReceivedLength
+		length++
+	case FuncCodeWriteFileRecord:
+		// The request is echoed
+		length = len(adu)
+	case FuncCodeEncapsulatedInterfaceTransport:
+		// MEI type, read device id code, conformity level, more follows,
+		// next object id and number of objects, see calculateReceivedLength
+		length += 6
 	case FuncCodeReadFIFOQueue:
 		// undetermined
 	default:
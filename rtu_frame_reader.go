@@ -0,0 +1,125 @@
+package modbus
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/jifanchn/serial"
+)
+
+var errFrameReaderClosed = errors.New("modbus: frame reader closed")
+
+// rtuFrameReader splits the byte stream of a serial line into RTU frames
+// delimited by an inter-frame silence. A goroutine keeps reading the
+// underlying port so that gaps between characters can be measured even
+// though the port only supports a blocking read with a fixed timeout.
+type rtuFrameReader struct {
+	chunks chan []byte
+	stop   chan struct{}
+	done   chan struct{}
+	// err is the error which stopped the read loop, valid once done is closed.
+	err error
+}
+
+// newRTUFrameReader starts reading r in the background.
+func newRTUFrameReader(r io.Reader) *rtuFrameReader {
+	fr := &rtuFrameReader{
+		chunks: make(chan []byte, 16),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go fr.readLoop(r)
+	return fr
+}
+
+func (fr *rtuFrameReader) readLoop(r io.Reader) {
+	defer close(fr.done)
+
+	var data [rtuMaxSize]byte
+	for {
+		n, err := r.Read(data[:])
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, data[:n])
+			select {
+			case fr.chunks <- chunk:
+			case <-fr.stop:
+				fr.err = errFrameReaderClosed
+				return
+			}
+		}
+		if err != nil {
+			// The port times out when the line is quiet, keep listening.
+			if err == serial.ErrTimeout {
+				continue
+			}
+			fr.err = err
+			return
+		}
+	}
+}
+
+// close stops delivering frames. The read loop returns once the pending
+// read on the port completes, i.e. when the port is closed or times out.
+func (fr *rtuFrameReader) close() {
+	select {
+	case <-fr.stop:
+	default:
+		close(fr.stop)
+	}
+}
+
+// readFrame waits for the first character of a frame, then collects
+// characters until the line has been silent for the given duration.
+// A nil timeout channel waits for the first character indefinitely.
+func (fr *rtuFrameReader) readFrame(silence time.Duration, timeout <-chan time.Time) (frame []byte, err error) {
+	select {
+	case chunk := <-fr.chunks:
+		frame = append(frame, chunk...)
+	case <-fr.done:
+		if frame = fr.drain(frame); len(frame) == 0 {
+			err = fr.err
+		}
+		return
+	case <-fr.stop:
+		err = errFrameReaderClosed
+		return
+	case <-timeout:
+		err = serial.ErrTimeout
+		return
+	}
+	timer := time.NewTimer(silence)
+	defer timer.Stop()
+	for {
+		select {
+		case chunk := <-fr.chunks:
+			frame = append(frame, chunk...)
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(silence)
+		case <-timer.C:
+			return
+		case <-fr.done:
+			// Deliver what has been received, the error is reported next time.
+			frame = fr.drain(frame)
+			return
+		case <-fr.stop:
+			err = errFrameReaderClosed
+			return
+		}
+	}
+}
+
+// drain appends the chunks already buffered to frame.
+func (fr *rtuFrameReader) drain(frame []byte) []byte {
+	for {
+		select {
+		case chunk := <-fr.chunks:
+			frame = append(frame, chunk...)
+		default:
+			return frame
+		}
+	}
+}
@@ -5,7 +5,7 @@
 package modbus
 
 import (
-	"io"
+	"context"
 	"time"
 )
 
@@ -21,6 +21,7 @@ func NewRTUOverTcpClientHandler(address string) *RTUOverTcpClientHandler {
 	handler.Address = address
 	handler.Timeout = tcpTimeout
 	handler.IdleTimeout = tcpIdleTimeout
+	handler.TurnaroundDelay = serialTurnaroundDelay
 	return handler
 }
 
@@ -28,9 +29,16 @@ func NewRTUOverTcpClientHandler(address string) *RTUOverTcpClientHandler {
 type rtuOverTcpTransporter struct {
 	TcpPort
 	BaudRate int
+	// Delay after a broadcast request, giving the slaves time to process it
+	TurnaroundDelay time.Duration
 }
 
 func (mb *rtuOverTcpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done.
+func (mb *rtuOverTcpTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	mb.Mu.Lock()
 	defer func() {
 		if mb.QueryDelayDuration > 0 {
@@ -38,9 +46,12 @@ func (mb *rtuOverTcpTransporter) Send(aduRequest []byte) (aduResponse []byte, er
 		}
 		mb.Mu.Unlock()
 	}()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
 	// Make sure port is connected
-	if err = mb.Connect(); err != nil {
+	if err = mb.ConnectContext(ctx); err != nil {
 		return
 	}
 	// Start the timer to close when idle
@@ -52,10 +63,20 @@ func (mb *rtuOverTcpTransporter) Send(aduRequest []byte) (aduResponse []byte, er
 	if mb.Timeout > 0 {
 		timeout = mb.LastActivity.Add(mb.Timeout)
 	}
-	if err = mb.Conn.SetDeadline(timeout); err != nil {
+	if err = mb.Conn.SetDeadline(contextDeadline(ctx, timeout)); err != nil {
 		_ = mb.ConnClose()
 		return
 	}
+	// Abort pending I/O when ctx is done
+	conn := mb.Conn
+	stop := watchContext(ctx, func() { _ = conn.SetDeadline(aLongTimeAgo) })
+	defer func() {
+		if interrupted := stop(); err != nil && (interrupted || ctx.Err() != nil) {
+			// The connection is left in an unknown state
+			_ = mb.ConnClose()
+			err = ctx.Err()
+		}
+	}()
 
 	// Send the request
 	mb.Debugf("modbus: sending % x", aduRequest)
@@ -63,43 +84,19 @@ func (mb *rtuOverTcpTransporter) Send(aduRequest []byte) (aduResponse []byte, er
 		_ = mb.ConnClose()
 		return
 	}
-	function := aduRequest[1]
-	functionFail := aduRequest[1] & 0x80
 	bytesToRead := calculateResponseLength(aduRequest)
-	time.Sleep(mb.calculateDelay(len(aduRequest) + bytesToRead))
-
-	var n int
-	var n1 int
-	var data [rtuMaxSize]byte
-	//We first read the minimum length and then read either the full package
-	//or the error package, depending on the error status (byte 2 of the response)
-	n, err = io.ReadAtLeast(mb.Conn, data[:], rtuMinSize)
-	if err != nil {
+	if err = sleepContext(ctx, mb.calculateDelay(len(aduRequest)+bytesToRead)); err != nil {
 		return
 	}
-	//if the function is correct
-	if data[1] == function {
-		//we read the rest of the bytes
-		if n < bytesToRead {
-			if bytesToRead > rtuMinSize && bytesToRead <= rtuMaxSize {
-				if bytesToRead > n {
-					n1, err = io.ReadFull(mb.Conn, data[n:bytesToRead])
-					n += n1
-				}
-			}
-		}
-	} else if data[1] == functionFail {
-		//for error, we need to read 5 bytes
-		if n < rtuExceptionSize {
-			n1, err = io.ReadFull(mb.Conn, data[n:rtuExceptionSize])
+	if bytesToRead == 0 {
+		if aduRequest[0] == 0 {
+			err = sleepContext(ctx, mb.TurnaroundDelay)
 		}
-		n += n1
+		return
 	}
-
-	if err != nil {
+	if aduResponse, err = readRTUResponse(mb.Conn, aduRequest); err != nil {
 		return
 	}
-	aduResponse = data[:n]
 	mb.Debugf("modbus: received % x", aduResponse)
 	return
 }
@@ -107,14 +104,5 @@ func (mb *rtuOverTcpTransporter) Send(aduRequest []byte) (aduResponse []byte, er
 // calculateDelay roughly calculates time needed for the next frame.
 // See MODBUS over Serial Line - Specification and Implementation Guide (page 13).
 func (mb *rtuOverTcpTransporter) calculateDelay(chars int) time.Duration {
-	var characterDelay, frameDelay int // us
-
-	if mb.BaudRate <= 0 || mb.BaudRate > 19200 {
-		characterDelay = 750
-		frameDelay = 1750
-	} else {
-		characterDelay = 15000000 / mb.BaudRate
-		frameDelay = 35000000 / mb.BaudRate
-	}
-	return time.Duration(characterDelay*chars+frameDelay) * time.Microsecond
+	return rtuDelay(mb.BaudRate, chars)
 }
@@ -0,0 +1,119 @@
+package modbus
+
+import (
+	"io"
+)
+
+// RTUServer is a Modbus RTU server (slave) on a serial line. It answers
+// requests addressed to any of SlaveIds and silently ignores traffic for
+// other slaves on the bus.
+type RTUServer struct {
+	SerialPort
+	// Slave ids served on the bus
+	SlaveIds []byte
+	// Data model serving the requests
+	Handler ServerHandler
+
+	closed bool
+	frames *rtuFrameReader
+}
+
+// NewRTUServer allocates and initializes a RTUServer.
+func NewRTUServer(address string, handler ServerHandler, slaveIds ...byte) *RTUServer {
+	s := &RTUServer{
+		SlaveIds: slaveIds,
+		Handler:  handler,
+	}
+	s.Address = address
+	s.Timeout = serialTimeout
+	return s
+}
+
+// ListenAndServe opens the serial port and serves requests until Close is
+// called or the port fails.
+func (s *RTUServer) ListenAndServe() error {
+	s.Mu.Lock()
+	if s.closed {
+		s.Mu.Unlock()
+		return ErrServerClosed
+	}
+	if err := s.Connect(); err != nil {
+		s.Mu.Unlock()
+		return err
+	}
+	conn := s.Conn
+	frames := newRTUFrameReader(conn)
+	s.frames = frames
+	s.Mu.Unlock()
+
+	defer frames.close()
+	for {
+		// Frames are delimited by the 3.5 character silence
+		frame, err := frames.readFrame(rtuDelay(s.BaudRate, 0), nil)
+		if err != nil {
+			s.Mu.Lock()
+			closed := s.closed
+			s.Mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if err = s.serveFrame(conn, frame); err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the serial port and makes ListenAndServe return.
+func (s *RTUServer) Close() (err error) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	s.closed = true
+	if s.frames != nil {
+		s.frames.close()
+	}
+	return s.ConnClose()
+}
+
+// serveFrame handles a single frame received from the bus. Frames with an
+// invalid CRC or addressed to other slaves are dropped.
+func (s *RTUServer) serveFrame(w io.Writer, frame []byte) (err error) {
+	if len(frame) < rtuMinSize || len(frame) > rtuMaxSize {
+		s.Debugf("modbus: dropping frame of invalid length % x", frame)
+		return
+	}
+	packager := RtuPackager{SlaveId: frame[0]}
+	request, err := packager.Decode(frame)
+	if err != nil {
+		s.Debugf("modbus: dropping frame % x: %v", frame, err)
+		return nil
+	}
+	broadcast := frame[0] == 0
+	if !broadcast && !s.serves(frame[0]) {
+		return
+	}
+	s.Debugf("modbus: received % x", frame)
+	response := handlePDU(s.Handler, frame[0], request)
+	// Broadcast requests are never answered
+	if broadcast {
+		return
+	}
+	aduResponse, err := packager.Encode(response)
+	if err != nil {
+		return
+	}
+	s.Debugf("modbus: sending % x", aduResponse)
+	_, err = w.Write(aduResponse)
+	return
+}
+
+func (s *RTUServer) serves(slaveId byte) bool {
+	for _, id := range s.SlaveIds {
+		if id == slaveId {
+			return true
+		}
+	}
+	return false
+}
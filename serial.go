@@ -5,6 +5,7 @@
 package modbus
 
 import (
+	"context"
 	"io"
 	"sync"
 	"time"
@@ -16,6 +17,8 @@ const (
 	// Default timeout
 	serialTimeout     = 5 * time.Second
 	serialIdleTimeout = 60 * time.Second
+	// Default delay after a broadcast request
+	serialTurnaroundDelay = 100 * time.Millisecond
 )
 
 func NewSerialPort(c serial.Config, idleTimeout time.Duration) *SerialPort {
@@ -24,8 +27,9 @@ func NewSerialPort(c serial.Config, idleTimeout time.Duration) *SerialPort {
 	}
 
 	sp := &SerialPort{
-		Config:      c,
-		IdleTimeout: idleTimeout,
+		Config:          c,
+		IdleTimeout:     idleTimeout,
+		TurnaroundDelay: serialTurnaroundDelay,
 	}
 	if sp.IdleTimeout == 0 {
 		sp.IdleTimeout = serialIdleTimeout
@@ -39,7 +43,9 @@ type SerialPort struct {
 	serial.Config
 	IdleTimeout        time.Duration
 	QueryDelayDuration time.Duration // Query delay duration
-	Logger             Logger
+	// Delay after a broadcast request, giving the slaves time to process it
+	TurnaroundDelay time.Duration
+	Logger          Logger
 
 	Mu           sync.Mutex
 	Conn         io.ReadWriteCloser // port is platform-dependent data structure for serial port.
@@ -49,6 +55,15 @@ type SerialPort struct {
 
 // Connect connects to the serial port if it is not connected. Caller must hold the mutex.
 func (mb *SerialPort) Connect() error {
+	return mb.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect which fails when ctx is already done.
+// Caller must hold the mutex.
+func (mb *SerialPort) ConnectContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if mb.Conn == nil {
 		port, err := serial.Open(&mb.Config)
 		if err != nil {
@@ -0,0 +1,398 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// ServerHandler is the data model a server delegates register access to.
+// Implementations must be safe for concurrent use. Returning a *ModbusError
+// sends its exception code back to the client, any other error is reported
+// as a server device failure.
+type ServerHandler interface {
+	// ReadCoils returns quantity coils starting at address.
+	ReadCoils(slaveId byte, address, quantity uint16) (results []bool, err error)
+	// ReadDiscreteInputs returns quantity discrete inputs starting at address.
+	ReadDiscreteInputs(slaveId byte, address, quantity uint16) (results []bool, err error)
+	// WriteCoils sets the coils starting at address.
+	WriteCoils(slaveId byte, address uint16, values []bool) (err error)
+	// ReadHoldingRegisters returns quantity holding registers starting at address.
+	ReadHoldingRegisters(slaveId byte, address, quantity uint16) (results []uint16, err error)
+	// ReadInputRegisters returns quantity input registers starting at address.
+	ReadInputRegisters(slaveId byte, address, quantity uint16) (results []uint16, err error)
+	// WriteHoldingRegisters sets the holding registers starting at address.
+	WriteHoldingRegisters(slaveId byte, address uint16, values []uint16) (err error)
+}
+
+// MaskWriteHandler is implemented by the ServerHandlers applying a mask
+// write atomically. For the others, the register is read and written in two
+// calls, which the requests of other connections may interleave with.
+type MaskWriteHandler interface {
+	// MaskWriteHoldingRegister sets the holding register at address to
+	// (value AND andMask) OR (orMask AND NOT andMask).
+	MaskWriteHoldingRegister(slaveId byte, address, andMask, orMask uint16) (err error)
+}
+
+// handlePDU decodes a request, dispatches it to the handler and returns
+// either the normal or the exception response.
+func handlePDU(handler ServerHandler, slaveId byte, request *ProtocolDataUnit) (response *ProtocolDataUnit) {
+	data, err := dispatchPDU(handler, slaveId, request)
+	if err != nil {
+		return exceptionResponse(request.FunctionCode, err)
+	}
+	return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: data}
+}
+
+func dispatchPDU(handler ServerHandler, slaveId byte, request *ProtocolDataUnit) (data []byte, err error) {
+	req := request.Data
+	switch request.FunctionCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs:
+		if len(req) != 4 {
+			return nil, illegalDataValue(request)
+		}
+		address, quantity := binary.BigEndian.Uint16(req), binary.BigEndian.Uint16(req[2:])
+		if err = checkQuantity(request, address, quantity, MaxReadBits); err != nil {
+			return
+		}
+		var results []bool
+		if request.FunctionCode == FuncCodeReadCoils {
+			results, err = handler.ReadCoils(slaveId, address, quantity)
+		} else {
+			results, err = handler.ReadDiscreteInputs(slaveId, address, quantity)
+		}
+		if err != nil {
+			return
+		}
+		if len(results) != int(quantity) {
+			return nil, serverDeviceFailure(request)
+		}
+		bits := packBits(results)
+		data = append([]byte{byte(len(bits))}, bits...)
+	case FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters:
+		if len(req) != 4 {
+			return nil, illegalDataValue(request)
+		}
+		address, quantity := binary.BigEndian.Uint16(req), binary.BigEndian.Uint16(req[2:])
+		if err = checkQuantity(request, address, quantity, MaxReadRegisters); err != nil {
+			return
+		}
+		var results []uint16
+		if request.FunctionCode == FuncCodeReadHoldingRegisters {
+			results, err = handler.ReadHoldingRegisters(slaveId, address, quantity)
+		} else {
+			results, err = handler.ReadInputRegisters(slaveId, address, quantity)
+		}
+		if err != nil {
+			return
+		}
+		if len(results) != int(quantity) {
+			return nil, serverDeviceFailure(request)
+		}
+		data = append([]byte{byte(2 * quantity)}, packRegisters(results)...)
+	case FuncCodeWriteSingleCoil:
+		if len(req) != 4 {
+			return nil, illegalDataValue(request)
+		}
+		address, value := binary.BigEndian.Uint16(req), binary.BigEndian.Uint16(req[2:])
+		if value != 0xFF00 && value != 0x0000 {
+			return nil, illegalDataValue(request)
+		}
+		if err = handler.WriteCoils(slaveId, address, []bool{value == 0xFF00}); err != nil {
+			return
+		}
+		data = req
+	case FuncCodeWriteSingleRegister:
+		if len(req) != 4 {
+			return nil, illegalDataValue(request)
+		}
+		address, value := binary.BigEndian.Uint16(req), binary.BigEndian.Uint16(req[2:])
+		if err = handler.WriteHoldingRegisters(slaveId, address, []uint16{value}); err != nil {
+			return
+		}
+		data = req
+	case FuncCodeWriteMultipleCoils:
+		if len(req) < 5 {
+			return nil, illegalDataValue(request)
+		}
+		address, quantity := binary.BigEndian.Uint16(req), binary.BigEndian.Uint16(req[2:])
+		if err = checkQuantity(request, address, quantity, MaxWriteBits); err != nil {
+			return
+		}
+		count := int(req[4])
+		if count != (int(quantity)+7)/8 || len(req) != 5+count {
+			return nil, illegalDataValue(request)
+		}
+		if err = handler.WriteCoils(slaveId, address, unpackBits(req[5:], int(quantity))); err != nil {
+			return
+		}
+		data = req[:4]
+	case FuncCodeWriteMultipleRegisters:
+		if len(req) < 5 {
+			return nil, illegalDataValue(request)
+		}
+		address, quantity := binary.BigEndian.Uint16(req), binary.BigEndian.Uint16(req[2:])
+		if err = checkQuantity(request, address, quantity, MaxWriteRegisters); err != nil {
+			return
+		}
+		count := int(req[4])
+		if count != 2*int(quantity) || len(req) != 5+count {
+			return nil, illegalDataValue(request)
+		}
+		if err = handler.WriteHoldingRegisters(slaveId, address, unpackRegisters(req[5:])); err != nil {
+			return
+		}
+		data = req[:4]
+	case FuncCodeMaskWriteRegister:
+		if len(req) != 6 {
+			return nil, illegalDataValue(request)
+		}
+		address := binary.BigEndian.Uint16(req)
+		andMask, orMask := binary.BigEndian.Uint16(req[2:]), binary.BigEndian.Uint16(req[4:])
+		if maskWriter, ok := handler.(MaskWriteHandler); ok {
+			if err = maskWriter.MaskWriteHoldingRegister(slaveId, address, andMask, orMask); err != nil {
+				return
+			}
+			data = req
+			break
+		}
+		var results []uint16
+		if results, err = handler.ReadHoldingRegisters(slaveId, address, 1); err != nil {
+			return
+		}
+		if len(results) != 1 {
+			return nil, serverDeviceFailure(request)
+		}
+		value := maskValue(results[0], andMask, orMask)
+		if err = handler.WriteHoldingRegisters(slaveId, address, []uint16{value}); err != nil {
+			return
+		}
+		data = req
+	case FuncCodeReadWriteMultipleRegisters:
+		if len(req) < 9 {
+			return nil, illegalDataValue(request)
+		}
+		readAddress, readQuantity := binary.BigEndian.Uint16(req), binary.BigEndian.Uint16(req[2:])
+		writeAddress, writeQuantity := binary.BigEndian.Uint16(req[4:]), binary.BigEndian.Uint16(req[6:])
+		if err = checkQuantity(request, readAddress, readQuantity, MaxReadRegisters); err != nil {
+			return
+		}
+		if err = checkQuantity(request, writeAddress, writeQuantity, MaxReadWriteRegisters); err != nil {
+			return
+		}
+		count := int(req[8])
+		if count != 2*int(writeQuantity) || len(req) != 9+count {
+			return nil, illegalDataValue(request)
+		}
+		// The write operation is performed before the read.
+		if err = handler.WriteHoldingRegisters(slaveId, writeAddress, unpackRegisters(req[9:])); err != nil {
+			return
+		}
+		var results []uint16
+		if results, err = handler.ReadHoldingRegisters(slaveId, readAddress, readQuantity); err != nil {
+			return
+		}
+		if len(results) != int(readQuantity) {
+			return nil, serverDeviceFailure(request)
+		}
+		data = append([]byte{byte(2 * readQuantity)}, packRegisters(results)...)
+	case FuncCodeReadFIFOQueue:
+		if len(req) != 2 {
+			return nil, illegalDataValue(request)
+		}
+		// The FIFO count register is followed by the queued registers.
+		address := binary.BigEndian.Uint16(req)
+		var results []uint16
+		if results, err = handler.ReadHoldingRegisters(slaveId, address, 1); err != nil {
+			return
+		}
+		if len(results) != 1 {
+			return nil, serverDeviceFailure(request)
+		}
+		count := results[0]
+		if count > 31 {
+			return nil, illegalDataValue(request)
+		}
+		if count > 0 {
+			if int(address)+1+int(count) > 0x10000 {
+				return nil, &ModbusError{FunctionCode: request.FunctionCode, ExceptionCode: ExceptionCodeIllegalDataAddress}
+			}
+			if results, err = handler.ReadHoldingRegisters(slaveId, address+1, count); err != nil {
+				return
+			}
+			if len(results) != int(count) {
+				return nil, serverDeviceFailure(request)
+			}
+		} else {
+			results = nil
+		}
+		data = make([]byte, 4, 4+2*count)
+		binary.BigEndian.PutUint16(data, 2+2*count)
+		binary.BigEndian.PutUint16(data[2:], count)
+		data = append(data, packRegisters(results)...)
+	default:
+		err = &ModbusError{FunctionCode: request.FunctionCode, ExceptionCode: ExceptionCodeIllegalFunction}
+	}
+	return
+}
+
+// checkQuantity validates quantity against the function limit and ensures
+// the addressed range does not run past the end of the address space.
+func checkQuantity(request *ProtocolDataUnit, address, quantity, max uint16) error {
+	if quantity < 1 || quantity > max {
+		return illegalDataValue(request)
+	}
+	if int(address)+int(quantity) > 0x10000 {
+		return &ModbusError{FunctionCode: request.FunctionCode, ExceptionCode: ExceptionCodeIllegalDataAddress}
+	}
+	return nil
+}
+
+func illegalDataValue(request *ProtocolDataUnit) error {
+	return &ModbusError{FunctionCode: request.FunctionCode, ExceptionCode: ExceptionCodeIllegalDataValue}
+}
+
+func serverDeviceFailure(request *ProtocolDataUnit) error {
+	return &ModbusError{FunctionCode: request.FunctionCode, ExceptionCode: ExceptionCodeServerDeviceFailure}
+}
+
+// exceptionResponse converts an error returned while serving a request to
+// an exception response.
+func exceptionResponse(functionCode byte, err error) *ProtocolDataUnit {
+	exceptionCode := byte(ExceptionCodeServerDeviceFailure)
+	if mbError, ok := err.(*ModbusError); ok && mbError.ExceptionCode != 0 {
+		exceptionCode = mbError.ExceptionCode
+	}
+	return &ProtocolDataUnit{FunctionCode: functionCode | 0x80, Data: []byte{exceptionCode}}
+}
+
+// packBits packs a sequence of bits, LSB first, into bytes.
+func packBits(values []bool) []byte {
+	data := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			data[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return data
+}
+
+// unpackBits unpacks quantity bits, LSB first, from data.
+func unpackBits(data []byte, quantity int) []bool {
+	values := make([]bool, quantity)
+	for i := range values {
+		values[i] = data[i/8]&(1<<uint(i%8)) != 0
+	}
+	return values
+}
+
+// packRegisters encodes registers in big-endian order.
+func packRegisters(values []uint16) []byte {
+	data := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(data[i*2:], v)
+	}
+	return data
+}
+
+// unpackRegisters decodes big-endian registers.
+func unpackRegisters(data []byte) []uint16 {
+	values := make([]uint16, len(data)/2)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	return values
+}
+
+// MemoryHandler implements ServerHandler by keeping the four tables in
+// memory. The same tables are served for every slave id.
+type MemoryHandler struct {
+	Mu               sync.RWMutex
+	Coils            []bool
+	DiscreteInputs   []bool
+	HoldingRegisters []uint16
+	InputRegisters   []uint16
+}
+
+// NewMemoryHandler allocates a MemoryHandler with tables of the given sizes.
+func NewMemoryHandler(coils, discreteInputs, holdingRegisters, inputRegisters int) *MemoryHandler {
+	return &MemoryHandler{
+		Coils:            make([]bool, coils),
+		DiscreteInputs:   make([]bool, discreteInputs),
+		HoldingRegisters: make([]uint16, holdingRegisters),
+		InputRegisters:   make([]uint16, inputRegisters),
+	}
+}
+
+func (h *MemoryHandler) ReadCoils(slaveId byte, address, quantity uint16) (results []bool, err error) {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	return readTable(h.Coils, FuncCodeReadCoils, address, quantity)
+}
+
+func (h *MemoryHandler) ReadDiscreteInputs(slaveId byte, address, quantity uint16) (results []bool, err error) {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	return readTable(h.DiscreteInputs, FuncCodeReadDiscreteInputs, address, quantity)
+}
+
+func (h *MemoryHandler) WriteCoils(slaveId byte, address uint16, values []bool) (err error) {
+	h.Mu.Lock()
+	defer h.Mu.Unlock()
+	return writeTable(h.Coils, FuncCodeWriteMultipleCoils, address, values)
+}
+
+func (h *MemoryHandler) ReadHoldingRegisters(slaveId byte, address, quantity uint16) (results []uint16, err error) {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	return readTable(h.HoldingRegisters, FuncCodeReadHoldingRegisters, address, quantity)
+}
+
+func (h *MemoryHandler) ReadInputRegisters(slaveId byte, address, quantity uint16) (results []uint16, err error) {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	return readTable(h.InputRegisters, FuncCodeReadInputRegisters, address, quantity)
+}
+
+func (h *MemoryHandler) WriteHoldingRegisters(slaveId byte, address uint16, values []uint16) (err error) {
+	h.Mu.Lock()
+	defer h.Mu.Unlock()
+	return writeTable(h.HoldingRegisters, FuncCodeWriteMultipleRegisters, address, values)
+}
+
+// MaskWriteHoldingRegister applies the masks to the holding register under
+// the lock, so that concurrent mask writes do not lose an update.
+func (h *MemoryHandler) MaskWriteHoldingRegister(slaveId byte, address, andMask, orMask uint16) (err error) {
+	h.Mu.Lock()
+	defer h.Mu.Unlock()
+	if int(address) >= len(h.HoldingRegisters) {
+		return &ModbusError{FunctionCode: FuncCodeMaskWriteRegister, ExceptionCode: ExceptionCodeIllegalDataAddress}
+	}
+	h.HoldingRegisters[address] = maskValue(h.HoldingRegisters[address], andMask, orMask)
+	return
+}
+
+// maskValue returns the register value after a mask write.
+func maskValue(value, andMask, orMask uint16) uint16 {
+	return (value & andMask) | (orMask &^ andMask)
+}
+
+func readTable[T any](table []T, functionCode byte, address, quantity uint16) (results []T, err error) {
+	end := int(address) + int(quantity)
+	if end > len(table) {
+		err = &ModbusError{FunctionCode: functionCode, ExceptionCode: ExceptionCodeIllegalDataAddress}
+		return
+	}
+	results = make([]T, quantity)
+	copy(results, table[address:end])
+	return
+}
+
+func writeTable[T any](table []T, functionCode byte, address uint16, values []T) (err error) {
+	end := int(address) + len(values)
+	if end > len(table) {
+		err = &ModbusError{FunctionCode: functionCode, ExceptionCode: ExceptionCodeIllegalDataAddress}
+		return
+	}
+	copy(table[address:end], values)
+	return
+}
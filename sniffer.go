@@ -0,0 +1,451 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jifanchn/serial"
+)
+
+// Default time a request waits for its response on a sniffed bus
+const snifferResponseTimeout = time.Second
+
+var ErrSnifferClosed = errors.New("modbus: sniffer closed")
+
+// HexBytes is a frame, formatted as hexadecimal in JSON.
+type HexBytes []byte
+
+func (b HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (b *HexBytes) UnmarshalText(text []byte) (err error) {
+	*b, err = hex.DecodeString(string(text))
+	return
+}
+
+// SnifferRecord is a transaction observed on the bus: a request and its
+// response, if any. The values of reads are taken from the response, the
+// values of writes from the request.
+type SnifferRecord struct {
+	// Time the request was received
+	Time time.Time `json:"time"`
+	// Time from the request to the response, in nanoseconds in JSON
+	Latency      time.Duration `json:"latency,omitempty"`
+	SlaveId      byte          `json:"slaveId"`
+	FunctionCode byte          `json:"functionCode"`
+	Function     string        `json:"function"`
+	// Address and quantity of the coils or registers, if the function
+	// addresses coils or registers
+	Address  uint16   `json:"address"`
+	Quantity uint16   `json:"quantity"`
+	Values   []uint16 `json:"values,omitempty"`
+	Bits     []bool   `json:"bits,omitempty"`
+	// Written part of read/write multiple registers
+	WriteAddress uint16   `json:"writeAddress,omitempty"`
+	WriteValues  []uint16 `json:"writeValues,omitempty"`
+	// Exception code of the response
+	Exception byte `json:"exception,omitempty"`
+	// Exception name, or why the response is missing or malformed
+	Error    string   `json:"error,omitempty"`
+	Request  HexBytes `json:"request"`
+	Response HexBytes `json:"response,omitempty"`
+}
+
+// RTUSniffer listens to the traffic of a Modbus RTU serial line without
+// transmitting. Frames are delimited by the inter-frame silence and their
+// CRC, requests are paired with their responses and each transaction is
+// decoded into a SnifferRecord.
+type RTUSniffer struct {
+	SerialPort
+	// FrameSilence overrides the 3.5 character silence ending a frame, for
+	// USB adapters which deliver the characters in batches.
+	FrameSilence time.Duration
+	// Time a request waits for its response before being reported
+	// unanswered
+	ResponseTimeout time.Duration
+
+	// Callback, if set, is called with each record.
+	Callback func(record SnifferRecord)
+	// Output, if set, receives each record as a line of JSON.
+	Output io.Writer
+
+	closed bool
+	frames *rtuFrameReader
+}
+
+// NewRTUSniffer allocates and initializes a RTUSniffer.
+func NewRTUSniffer(address string) *RTUSniffer {
+	s := &RTUSniffer{
+		ResponseTimeout: snifferResponseTimeout,
+	}
+	s.Address = address
+	s.Timeout = serialTimeout
+	return s
+}
+
+// Listen opens the serial port and reports the transactions until Close is
+// called, the port fails or writing to Output fails.
+func (s *RTUSniffer) Listen() error {
+	s.Mu.Lock()
+	if s.closed {
+		s.Mu.Unlock()
+		return ErrSnifferClosed
+	}
+	if err := s.Connect(); err != nil {
+		s.Mu.Unlock()
+		return err
+	}
+	frames := newRTUFrameReader(s.Conn)
+	s.frames = frames
+	s.Mu.Unlock()
+
+	defer frames.close()
+	silence := s.FrameSilence
+	if silence <= 0 {
+		silence = rtuDelay(s.BaudRate, 0)
+	}
+	responseTimeout := s.ResponseTimeout
+	if responseTimeout <= 0 {
+		responseTimeout = snifferResponseTimeout
+	}
+
+	// Characters which may start a frame continued by the next chunk
+	var data []byte
+	// Request waiting for its response
+	var pending *SnifferRecord
+	for {
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if pending != nil {
+			timer = time.NewTimer(time.Until(pending.Time.Add(responseTimeout)))
+			timeout = timer.C
+		}
+		chunk, err := frames.readFrame(silence, timeout)
+		if timer != nil {
+			timer.Stop()
+		}
+		if err == serial.ErrTimeout {
+			if err = s.emit(pending); err != nil {
+				return err
+			}
+			pending = nil
+			continue
+		}
+		if err != nil {
+			s.Mu.Lock()
+			closed := s.closed
+			s.Mu.Unlock()
+			if closed {
+				return ErrSnifferClosed
+			}
+			return err
+		}
+		now := time.Now()
+		data = append(data, chunk...)
+		for {
+			var request []byte
+			if pending != nil && now.Sub(pending.Time) <= responseTimeout {
+				request = pending.Request
+			}
+			var frame []byte
+			if frame, data = nextRTUFrame(data, request); frame == nil {
+				break
+			}
+			if request != nil && answers(request, frame) {
+				pending.Latency = now.Sub(pending.Time)
+				decodeSnifferResponse(pending, frame)
+				if err = s.emit(pending); err != nil {
+					return err
+				}
+				pending = nil
+				continue
+			}
+			// The pending request was not answered
+			if err = s.emit(pending); err != nil {
+				return err
+			}
+			pending = newSnifferRecord(now, frame)
+			if pending.Error != "" || frame[0] == 0 || calculateResponseLength(frame) == 0 {
+				// Malformed, broadcast or not answered
+				if err = s.emit(pending); err != nil {
+					return err
+				}
+				pending = nil
+			}
+		}
+		if len(data) > rtuMaxSize {
+			s.Debugf("modbus: discarding % x", data[:len(data)-rtuMaxSize])
+			data = data[len(data)-rtuMaxSize:]
+		}
+	}
+}
+
+// Close closes the serial port and makes Listen return.
+func (s *RTUSniffer) Close() (err error) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	s.closed = true
+	if s.frames != nil {
+		s.frames.close()
+	}
+	return s.ConnClose()
+}
+
+// emit delivers the record, if not nil, to the callback and the output.
+func (s *RTUSniffer) emit(record *SnifferRecord) (err error) {
+	if record == nil {
+		return
+	}
+	if record.Response == nil && record.Error == "" && record.SlaveId != 0 && calculateResponseLength(record.Request) != 0 {
+		record.Error = "no response"
+	}
+	s.Debugf("modbus: sniffed % x, % x", record.Request, record.Response)
+	if s.Callback != nil {
+		s.Callback(*record)
+	}
+	if s.Output != nil {
+		var line []byte
+		if line, err = json.Marshal(record); err != nil {
+			return
+		}
+		_, err = s.Output.Write(append(line, '\n'))
+	}
+	return
+}
+
+// nextRTUFrame extracts the first frame with a valid CRC from data, the
+// response to aduRequest if not nil being expected. The characters before
+// the frame are noise, those after it are returned as rest as they may
+// start a frame continued later. frame is nil if data holds no frame.
+func nextRTUFrame(data, aduRequest []byte) (frame, rest []byte) {
+	for start := 0; start+rtuMinSize <= len(data); start++ {
+		if end := rtuFrameEnd(data[start:], aduRequest); end > 0 {
+			return data[start : start+end], data[start+end:]
+		}
+	}
+	return nil, data
+}
+
+// rtuFrameEnd returns the length of the frame starting data, 0 if none.
+// The response to aduRequest, if not nil, has the length computed from the
+// request when its CRC is valid at that length. Other frames end at the
+// first valid CRC, which may be within a longer frame.
+func rtuFrameEnd(data, aduRequest []byte) int {
+	if aduRequest != nil && data[0] == aduRequest[0] {
+		length := 0
+		if data[1] == aduRequest[1]|0x80 {
+			length = rtuExceptionSize
+		} else if data[1] == aduRequest[1] {
+			length = calculateReceivedLength(aduRequest, data)
+		}
+		// Responses of undetermined length end at the first valid CRC
+		if length > rtuMinSize && length <= len(data) && rtuChecksumValid(data[:length]) {
+			return length
+		}
+	}
+	var crc crc
+	crc.reset().pushBytes(data[:rtuMinSize-2])
+	for end := rtuMinSize; end <= len(data) && end <= rtuMaxSize; end++ {
+		if uint16(data[end-1])<<8|uint16(data[end-2]) == crc.value() {
+			return end
+		}
+		crc.pushBytes(data[end-2 : end-1])
+	}
+	return 0
+}
+
+// answers reports whether frame may be the response to the request
+// aduRequest, given its slave id, function code and length.
+func answers(aduRequest, frame []byte) bool {
+	if frame[0] != aduRequest[0] {
+		return false
+	}
+	if frame[1] == aduRequest[1]|0x80 {
+		return len(frame) == rtuExceptionSize
+	}
+	if frame[1] != aduRequest[1] {
+		return false
+	}
+	// Responses of undetermined length are accepted as is
+	length := calculateReceivedLength(aduRequest, frame)
+	return length <= rtuMinSize || length == len(frame)
+}
+
+// newSnifferRecord decodes the request frame.
+func newSnifferRecord(now time.Time, frame []byte) *SnifferRecord {
+	record := &SnifferRecord{
+		Time:         now,
+		SlaveId:      frame[0],
+		FunctionCode: frame[1],
+		Function:     functionName(frame[1]),
+		Request:      HexBytes(frame),
+	}
+	data := frame[2 : len(frame)-2]
+	switch record.FunctionCode {
+	case FuncCodeReadCoils,
+		FuncCodeReadDiscreteInputs,
+		FuncCodeReadHoldingRegisters,
+		FuncCodeReadInputRegisters,
+		FuncCodeWriteSingleCoil,
+		FuncCodeWriteSingleRegister,
+		FuncCodeWriteMultipleCoils,
+		FuncCodeWriteMultipleRegisters,
+		FuncCodeMaskWriteRegister,
+		FuncCodeReadWriteMultipleRegisters:
+		// The expected length of the response is computed from the address
+		// and quantity
+		if len(data) < 4 {
+			record.Error = "malformed request"
+			return record
+		}
+	}
+	switch record.FunctionCode {
+	case FuncCodeReadCoils,
+		FuncCodeReadDiscreteInputs,
+		FuncCodeReadHoldingRegisters,
+		FuncCodeReadInputRegisters:
+		record.Address = binary.BigEndian.Uint16(data)
+		record.Quantity = binary.BigEndian.Uint16(data[2:])
+	case FuncCodeWriteSingleCoil:
+		record.Address = binary.BigEndian.Uint16(data)
+		record.Quantity = 1
+		record.Bits = []bool{binary.BigEndian.Uint16(data[2:]) == 0xFF00}
+	case FuncCodeWriteSingleRegister:
+		record.Address = binary.BigEndian.Uint16(data)
+		record.Quantity = 1
+		record.Values = registerValues(data[2:4])
+	case FuncCodeWriteMultipleCoils:
+		if len(data) >= 5 {
+			record.Address = binary.BigEndian.Uint16(data)
+			record.Quantity = binary.BigEndian.Uint16(data[2:])
+			record.Bits = bitValues(data[5:], int(record.Quantity))
+		}
+	case FuncCodeWriteMultipleRegisters:
+		if len(data) >= 5 {
+			record.Address = binary.BigEndian.Uint16(data)
+			record.Quantity = binary.BigEndian.Uint16(data[2:])
+			record.Values = registerValues(data[5:])
+		}
+	case FuncCodeMaskWriteRegister:
+		// AND mask, OR mask
+		if len(data) >= 6 {
+			record.Address = binary.BigEndian.Uint16(data)
+			record.Quantity = 1
+			record.Values = registerValues(data[2:6])
+		}
+	case FuncCodeReadWriteMultipleRegisters:
+		if len(data) >= 9 {
+			record.Address = binary.BigEndian.Uint16(data)
+			record.Quantity = binary.BigEndian.Uint16(data[2:])
+			record.WriteAddress = binary.BigEndian.Uint16(data[4:])
+			record.WriteValues = registerValues(data[9:])
+		}
+	case FuncCodeReadFIFOQueue:
+		if len(data) >= 2 {
+			record.Address = binary.BigEndian.Uint16(data)
+		}
+	}
+	return record
+}
+
+// decodeSnifferResponse completes the record with the response frame.
+func decodeSnifferResponse(record *SnifferRecord, frame []byte) {
+	record.Response = HexBytes(frame)
+	data := frame[2 : len(frame)-2]
+	if frame[1]&0x80 != 0 {
+		record.Exception = data[0]
+		record.Error = exceptionName(data[0])
+		return
+	}
+	switch record.FunctionCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs:
+		if len(data) >= 1 {
+			record.Bits = bitValues(data[1:], int(record.Quantity))
+		}
+	case FuncCodeReadHoldingRegisters,
+		FuncCodeReadInputRegisters,
+		FuncCodeReadWriteMultipleRegisters:
+		if len(data) >= 1 {
+			record.Values = registerValues(data[1:])
+		}
+	case FuncCodeReadFIFOQueue:
+		// Byte count, FIFO count
+		if len(data) >= 4 {
+			record.Quantity = binary.BigEndian.Uint16(data[2:])
+			record.Values = registerValues(data[4:])
+		}
+	}
+}
+
+// registerValues decodes big-endian registers.
+func registerValues(data []byte) []uint16 {
+	values := make([]uint16, len(data)/2)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return values
+}
+
+// bitValues decodes up to quantity bits packed least significant first.
+func bitValues(data []byte, quantity int) []bool {
+	if quantity > 8*len(data) {
+		quantity = 8 * len(data)
+	}
+	bits := make([]bool, quantity)
+	for i := range bits {
+		bits[i] = data[i/8]&(1<<uint(i%8)) != 0
+	}
+	return bits
+}
+
+// functionName returns the name of a function code.
+func functionName(functionCode byte) string {
+	switch functionCode {
+	case FuncCodeReadCoils:
+		return "read coils"
+	case FuncCodeReadDiscreteInputs:
+		return "read discrete inputs"
+	case FuncCodeReadHoldingRegisters:
+		return "read holding registers"
+	case FuncCodeReadInputRegisters:
+		return "read input registers"
+	case FuncCodeWriteSingleCoil:
+		return "write single coil"
+	case FuncCodeWriteSingleRegister:
+		return "write single register"
+	case FuncCodeReadExceptionStatus:
+		return "read exception status"
+	case FuncCodeDiagnostics:
+		return "diagnostics"
+	case FuncCodeGetCommEventCounter:
+		return "get comm event counter"
+	case FuncCodeGetCommEventLog:
+		return "get comm event log"
+	case FuncCodeWriteMultipleCoils:
+		return "write multiple coils"
+	case FuncCodeWriteMultipleRegisters:
+		return "write multiple registers"
+	case FuncCodeReportServerId:
+		return "report server id"
+	case FuncCodeReadFileRecord:
+		return "read file record"
+	case FuncCodeWriteFileRecord:
+		return "write file record"
+	case FuncCodeMaskWriteRegister:
+		return "mask write register"
+	case FuncCodeReadWriteMultipleRegisters:
+		return "read write multiple registers"
+	case FuncCodeReadFIFOQueue:
+		return "read fifo queue"
+	case FuncCodeEncapsulatedInterfaceTransport:
+		return "encapsulated interface transport"
+	}
+	return fmt.Sprintf("function %v", functionCode)
+}
@@ -5,6 +5,8 @@
 package modbus
 
 import (
+	"context"
+	"crypto/tls"
 	"net"
 	"sync"
 	"time"
@@ -44,6 +46,8 @@ type TcpPort struct {
 	QueryDelayDuration time.Duration
 	// Transmission logger
 	Logger Logger
+	// TLS configuration, the connection is secured with TLS if set
+	TLSConfig *tls.Config
 
 	// TCP connection
 	Mu           sync.Mutex
@@ -52,10 +56,25 @@ type TcpPort struct {
 	LastActivity time.Time
 }
 
+// Connect connects to Address if it is not connected. Caller must hold the mutex.
 func (mb *TcpPort) Connect() error {
+	return mb.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect which gives up dialing when ctx is done.
+// Caller must hold the mutex.
+func (mb *TcpPort) ConnectContext(ctx context.Context) error {
 	if mb.Conn == nil {
 		dialer := net.Dialer{Timeout: mb.Timeout}
-		conn, err := dialer.Dial("tcp", mb.Address)
+		var conn net.Conn
+		var err error
+		if mb.TLSConfig != nil {
+			// The handshake is part of dialing
+			tlsDialer := tls.Dialer{NetDialer: &dialer, Config: mb.TLSConfig}
+			conn, err = tlsDialer.DialContext(ctx, "tcp", mb.Address)
+		} else {
+			conn, err = dialer.DialContext(ctx, "tcp", mb.Address)
+		}
 		if err != nil {
 			return err
 		}
@@ -5,6 +5,7 @@
 package modbus
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -44,6 +45,17 @@ type TcpPackager struct {
 	SlaveId byte
 }
 
+func (mb *TcpPackager) slaveId() byte {
+	return mb.SlaveId
+}
+
+// Unit id 0 addresses the server itself, gateways forward it as a broadcast.
+// It is not a broadcast for the client: many devices answer it as their own
+// unit id, so that requests to unit 0 are sent and answered like any other.
+func (mb *TcpPackager) broadcasts() bool {
+	return false
+}
+
 // Encode adds modbus application protocol header:
 //
 //	Transaction identifier: 2 bytes
@@ -53,6 +65,11 @@ type TcpPackager struct {
 //	Function code: 1 byte
 //	Data: n bytes
 func (mb *TcpPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
+	return mb.encodeSlave(pdu, mb.SlaveId)
+}
+
+// encodeSlave is Encode addressing the PDU to slaveId.
+func (mb *TcpPackager) encodeSlave(pdu *ProtocolDataUnit, slaveId byte) (adu []byte, err error) {
 	adu = make([]byte, tcpHeaderSize+1+len(pdu.Data))
 
 	// Transaction identifier
@@ -64,7 +81,7 @@ func (mb *TcpPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
 	length := uint16(1 + 1 + len(pdu.Data))
 	binary.BigEndian.PutUint16(adu[4:], length)
 	// Unit identifier
-	adu[6] = mb.SlaveId
+	adu[6] = slaveId
 
 	// PDU
 	adu[tcpHeaderSize] = pdu.FunctionCode
@@ -124,6 +141,11 @@ type tcpTransporter struct {
 
 // Send sends data to server and ensures response length is greater than header length.
 func (mb *tcpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done.
+func (mb *tcpTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	mb.Mu.Lock()
 	defer func() {
 		if mb.QueryDelayDuration > 0 {
@@ -131,9 +153,12 @@ func (mb *tcpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error
 		}
 		mb.Mu.Unlock()
 	}()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
 	// Establish a new connection if not connected
-	if err = mb.Connect(); err != nil {
+	if err = mb.ConnectContext(ctx); err != nil {
 		return
 	}
 	// Set timer to close when idle
@@ -144,16 +169,29 @@ func (mb *tcpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error
 	if mb.Timeout > 0 {
 		timeout = mb.LastActivity.Add(mb.Timeout)
 	}
-	if err = mb.Conn.SetDeadline(timeout); err != nil {
+	if err = mb.Conn.SetDeadline(contextDeadline(ctx, timeout)); err != nil {
 		_ = mb.ConnClose()
 		return
 	}
+	// Abort pending I/O when ctx is done
+	conn := mb.Conn
+	stop := watchContext(ctx, func() { _ = conn.SetDeadline(aLongTimeAgo) })
+	defer func() {
+		if interrupted := stop(); err != nil && (interrupted || ctx.Err() != nil) {
+			// The connection is left in an unknown state
+			_ = mb.ConnClose()
+			err = ctx.Err()
+		}
+	}()
 	// Send data
 	mb.Debugf("modbus: sending % x", aduRequest)
 	if _, err = mb.Conn.Write(aduRequest); err != nil {
 		_ = mb.ConnClose()
 		return
 	}
+	if !tcpResponseExpected(aduRequest) {
+		return
+	}
 	// Read header first
 	var data [tcpMaxLength]byte
 	if _, err = io.ReadFull(mb.Conn, data[:tcpHeaderSize]); err != nil {
@@ -180,3 +218,13 @@ func (mb *tcpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error
 	mb.Debugf("modbus: received % x", aduResponse)
 	return
 }
+
+// tcpResponseExpected reports whether the request adu is answered, which
+// is not the case when a device is forced into listen only mode.
+func tcpResponseExpected(adu []byte) bool {
+	if len(adu) < tcpHeaderSize+3 {
+		return true
+	}
+	return adu[tcpHeaderSize] != FuncCodeDiagnostics ||
+		binary.BigEndian.Uint16(adu[tcpHeaderSize+1:]) != DiagSubFuncForceListenOnlyMode
+}
@@ -0,0 +1,212 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed is returned by the servers' Serve methods after a call to Close.
+var ErrServerClosed = errors.New("modbus: server closed")
+
+// Server is a Modbus TCP server (slave) which serves requests from
+// any number of client connections using Handler.
+type Server struct {
+	// Listen address, e.g. ":502"
+	Address string
+	// Data model serving the requests
+	Handler ServerHandler
+	// Idle timeout to close a client connection
+	IdleTimeout time.Duration
+	// Transmission logger
+	Logger Logger
+
+	// serve, if set, answers the requests instead of Handler
+	serve func(unitId byte, request *ProtocolDataUnit) *ProtocolDataUnit
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer allocates a new Server.
+func NewServer(address string, handler ServerHandler) *Server {
+	return &Server{
+		Address:     address,
+		Handler:     handler,
+		IdleTimeout: tcpIdleTimeout,
+	}
+}
+
+// ListenAndServe listens on Address and serves incoming connections
+// until Close is called.
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the listener and serves each of them in its
+// own goroutine. It always returns a non-nil error and closes l.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			if netError, ok := err.(net.Error); ok && netError.Timeout() {
+				continue
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(conn)
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close stops listening, closes all client connections and waits for
+// their goroutines to return.
+func (s *Server) Close() (err error) {
+	s.mu.Lock()
+	s.closed = true
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// serveConn reads MBAP frames from conn and answers each of them until the
+// connection is closed, idle or sends a malformed header.
+func (s *Server) serveConn(conn net.Conn) {
+	s.Debugf("modbus: accepted connection from %v", conn.RemoteAddr())
+	var data [tcpMaxLength]byte
+	for {
+		if s.IdleTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(s.IdleTimeout)); err != nil {
+				return
+			}
+		}
+		aduRequest, err := readTcpFrame(conn, data[:])
+		if err != nil {
+			if err != io.EOF {
+				s.Debugf("modbus: closing connection from %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		s.Debugf("modbus: received % x", aduRequest)
+		request := &ProtocolDataUnit{
+			FunctionCode: aduRequest[tcpHeaderSize],
+			Data:         aduRequest[tcpHeaderSize+1:],
+		}
+		var response *ProtocolDataUnit
+		if s.serve != nil {
+			response = s.serve(aduRequest[6], request)
+		} else {
+			response = handlePDU(s.Handler, aduRequest[6], request)
+		}
+		aduResponse := encodeTcpResponse(aduRequest, response)
+		s.Debugf("modbus: sending % x", aduResponse)
+		if _, err = conn.Write(aduResponse); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) Debugf(format string, v ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Debugf(format, v...)
+	}
+}
+
+// readTcpFrame reads one MBAP frame into data, which must have a
+// capacity of at least tcpMaxLength bytes.
+func readTcpFrame(r io.Reader, data []byte) (adu []byte, err error) {
+	if _, err = io.ReadFull(r, data[:tcpHeaderSize]); err != nil {
+		return
+	}
+	if protocolId := binary.BigEndian.Uint16(data[2:]); protocolId != tcpProtocolIdentifier {
+		err = fmt.Errorf("modbus: protocol id '%v' does not match '%v'", protocolId, tcpProtocolIdentifier)
+		return
+	}
+	// Length covers unit id, function code and data
+	length := int(binary.BigEndian.Uint16(data[4:]))
+	if length < 2 || length > (tcpMaxLength-(tcpHeaderSize-1)) {
+		err = fmt.Errorf("modbus: length in header '%v' must be between '%v' and '%v'", length, 2, tcpMaxLength-tcpHeaderSize+1)
+		return
+	}
+	length += tcpHeaderSize - 1
+	if _, err = io.ReadFull(r, data[tcpHeaderSize:length]); err != nil {
+		return
+	}
+	adu = data[:length]
+	return
+}
+
+// encodeTcpResponse builds the MBAP response frame echoing the transaction,
+// protocol and unit id of the request.
+func encodeTcpResponse(aduRequest []byte, pdu *ProtocolDataUnit) (adu []byte) {
+	adu = make([]byte, tcpHeaderSize+1+len(pdu.Data))
+	copy(adu, aduRequest[:4])
+	binary.BigEndian.PutUint16(adu[4:], uint16(1+1+len(pdu.Data)))
+	adu[6] = aduRequest[6]
+	adu[tcpHeaderSize] = pdu.FunctionCode
+	copy(adu[tcpHeaderSize+1:], pdu.Data)
+	return
+}
@@ -0,0 +1,88 @@
+package modbus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"time"
+)
+
+// OIDModbusRole identifies the X.509 extension carrying the role granted
+// to the certificate holder by Modbus/TCP Security.
+var OIDModbusRole = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 50316, 802, 1}
+
+// TLSClientHandler implements Packager and Transporter interface for
+// Modbus/TCP Security, i.e. Modbus TCP over TLS, usually on port 802.
+type TLSClientHandler struct {
+	TcpPackager
+	tcpTransporter
+}
+
+// NewTLSClientHandler allocates a new TLSClientHandler. The configuration
+// holds the client certificates, the CA pool verifying the server and the
+// expected server name; it is cloned and requires TLS 1.2 at least, as
+// mandated by the specification.
+func NewTLSClientHandler(address string, config *tls.Config) *TLSClientHandler {
+	h := &TLSClientHandler{}
+	h.Address = address
+	h.Timeout = tcpTimeout
+	h.IdleTimeout = tcpIdleTimeout
+	if config == nil {
+		h.TLSConfig = &tls.Config{}
+	} else {
+		h.TLSConfig = config.Clone()
+	}
+	if h.TLSConfig.MinVersion < tls.VersionTLS12 {
+		h.TLSConfig.MinVersion = tls.VersionTLS12
+	}
+	return h
+}
+
+// PeerRole returns the role in the certificate presented by the server,
+// connecting first if needed. The role is empty if the certificate does
+// not carry the role extension.
+func (mb *TLSClientHandler) PeerRole() (role string, err error) {
+	mb.Mu.Lock()
+	defer mb.Mu.Unlock()
+
+	if err = mb.Connect(); err != nil {
+		return
+	}
+	// Set timer to close when idle
+	mb.LastActivity = time.Now()
+	mb.StartCloseTimer()
+	conn, ok := mb.Conn.(*tls.Conn)
+	if !ok {
+		err = fmt.Errorf("modbus: connection to '%v' is not secured with TLS", mb.Address)
+		return
+	}
+	certificates := conn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		err = fmt.Errorf("modbus: server '%v' presented no certificate", mb.Address)
+		return
+	}
+	return CertificateRole(certificates[0])
+}
+
+// CertificateRole returns the role carried by the Modbus role extension of
+// the certificate, or an empty string if the extension is absent.
+func CertificateRole(cert *x509.Certificate) (role string, err error) {
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(OIDModbusRole) {
+			continue
+		}
+		// The role is encoded as an ASN.1 UTF8String
+		var rest []byte
+		if rest, err = asn1.UnmarshalWithParams(extension.Value, &role, "utf8"); err != nil {
+			err = fmt.Errorf("modbus: invalid role extension: %v", err)
+			return
+		}
+		if len(rest) != 0 {
+			err = fmt.Errorf("modbus: invalid role extension: '%v' trailing bytes", len(rest))
+			return
+		}
+		return
+	}
+	return
+}
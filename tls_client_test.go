@@ -0,0 +1,88 @@
+package modbus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// selfSignedCertificate returns a certificate for 127.0.0.1 carrying the
+// Modbus role extension.
+func selfSignedCertificate(t *testing.T, role string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := asn1.MarshalWithParams(role, "utf8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "modbus test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtraExtensions:       []pkix.Extension{{Id: OIDModbusRole, Value: value}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestTLSClientHandler(t *testing.T) {
+	cert := selfSignedCertificate(t, "operator")
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := NewMemoryHandler(0, 0, 4, 0)
+	memory.HoldingRegisters[1] = 0x1234
+	server := NewServer("", memory)
+	go server.Serve(l)
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	handler := NewTLSClientHandler(l.Addr().String(), &tls.Config{RootCAs: roots})
+	handler.Timeout = 5 * time.Second
+	handler.SlaveId = 1
+	defer handler.Close()
+
+	results, err := NewClient(handler).ReadHoldingRegisters(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0] != 0x12 || results[1] != 0x34 {
+		t.Fatalf("unexpected results: % x", results)
+	}
+	role, err := handler.PeerRole()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != "operator" {
+		t.Fatalf("unexpected role: '%v'", role)
+	}
+}
@@ -0,0 +1,200 @@
+package modbus
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// Default UDP timeout of a single attempt
+	udpTimeout     = 3 * time.Second
+	udpIdleTimeout = 60 * time.Second
+	udpRetries     = 2
+
+	// Large enough for any datagram carrying an ADU
+	udpMaxLength = 1024
+)
+
+// NewUdpPort allocates a UdpPort with default timeout and retries.
+func NewUdpPort(address string, timeout, idleTimeout time.Duration) *UdpPort {
+	up := &UdpPort{
+		Address:     address,
+		Timeout:     timeout,
+		IdleTimeout: idleTimeout,
+		Retries:     udpRetries,
+	}
+	if up.Timeout == 0 {
+		up.Timeout = udpTimeout
+	}
+	if up.IdleTimeout == 0 {
+		up.IdleTimeout = udpIdleTimeout
+	}
+	return up
+}
+
+// UdpPort has configuration and I/O controller of a UDP socket.
+type UdpPort struct {
+	// Connect string
+	Address string
+	// Read timeout of a single attempt
+	Timeout time.Duration
+	// Number of times a request is sent again after a timeout. Requests
+	// changing the state of the device, such as writes, are sent once as
+	// they may have been executed although the response was lost, see
+	// RetryPolicy.RetryNonIdempotent to retry them.
+	Retries int
+	// Idle timeout to close the socket
+	IdleTimeout time.Duration
+	// Query delay duration
+	QueryDelayDuration time.Duration
+	// Transmission logger
+	Logger Logger
+
+	// UDP socket
+	Mu           sync.Mutex
+	Conn         net.Conn
+	closeTimer   *time.Timer
+	LastActivity time.Time
+}
+
+// Connect creates the socket if it is not created. Caller must hold the mutex.
+func (mb *UdpPort) Connect() error {
+	return mb.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect which gives up resolving Address when ctx is
+// done. Caller must hold the mutex.
+func (mb *UdpPort) ConnectContext(ctx context.Context) error {
+	if mb.Conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "udp", mb.Address)
+		if err != nil {
+			return err
+		}
+		mb.Conn = conn
+	}
+	return nil
+}
+
+// Close closes the socket.
+func (mb *UdpPort) Close() error {
+	mb.Mu.Lock()
+	defer mb.Mu.Unlock()
+
+	return mb.ConnClose()
+}
+
+// ConnClose closes the socket. Caller must hold the mutex.
+func (mb *UdpPort) ConnClose() (err error) {
+	if mb.Conn != nil {
+		err = mb.Conn.Close()
+		mb.Conn = nil
+	}
+	return
+}
+
+func (mb *UdpPort) StartCloseTimer() {
+	if mb.IdleTimeout <= 0 {
+		return
+	}
+	if mb.closeTimer == nil {
+		mb.closeTimer = time.AfterFunc(mb.IdleTimeout, mb.closeIdle)
+	} else {
+		mb.closeTimer.Reset(mb.IdleTimeout)
+	}
+}
+
+// closeIdle closes the socket if last activity is passed behind IdleTimeout.
+func (mb *UdpPort) closeIdle() {
+	mb.Mu.Lock()
+	defer mb.Mu.Unlock()
+
+	if mb.IdleTimeout <= 0 {
+		return
+	}
+	idle := time.Now().Sub(mb.LastActivity)
+	if idle >= mb.IdleTimeout {
+		mb.Debugf("modbus: closing connection due to idle timeout: %v", idle)
+		mb.ConnClose()
+	}
+}
+
+func (mb *UdpPort) Debugf(format string, v ...interface{}) {
+	if mb.Logger != nil {
+		mb.Logger.Debugf(format, v...)
+	}
+}
+
+// exchange sends the request in a single datagram and waits for a datagram
+// accepted by match, discarding the others. The request is sent again up
+// to retries times when no response arrives within Timeout.
+// Caller must hold the mutex.
+func (mb *UdpPort) exchange(ctx context.Context, aduRequest []byte, retries int, match func(aduResponse []byte) bool) (aduResponse []byte, err error) {
+	// Make sure the socket is created
+	if err = mb.ConnectContext(ctx); err != nil {
+		return
+	}
+	// Start the timer to close when idle
+	mb.LastActivity = time.Now()
+	mb.StartCloseTimer()
+
+	// Abort pending I/O when ctx is done
+	conn := mb.Conn
+	stop := watchContext(ctx, func() { _ = conn.SetDeadline(aLongTimeAgo) })
+	defer func() {
+		if interrupted := stop(); err != nil && (interrupted || ctx.Err() != nil) {
+			err = ctx.Err()
+		}
+	}()
+
+	var data [udpMaxLength]byte
+	for attempt := 0; ; attempt++ {
+		var timeout time.Time
+		if mb.Timeout > 0 {
+			timeout = time.Now().Add(mb.Timeout)
+		}
+		if err = conn.SetDeadline(contextDeadline(ctx, timeout)); err != nil {
+			_ = mb.ConnClose()
+			return
+		}
+		mb.Debugf("modbus: sending % x", aduRequest)
+		if _, err = conn.Write(aduRequest); err != nil {
+			_ = mb.ConnClose()
+			return
+		}
+		if match == nil {
+			// No response is expected
+			return
+		}
+		for {
+			var n int
+			if n, err = conn.Read(data[:]); err != nil {
+				break
+			}
+			if match(data[:n]) {
+				aduResponse = make([]byte, n)
+				copy(aduResponse, data[:n])
+				mb.Debugf("modbus: received % x", aduResponse)
+				return
+			}
+			mb.Debugf("modbus: discarding unexpected datagram % x", data[:n])
+		}
+		netError, ok := err.(net.Error)
+		if !ok || !netError.Timeout() || attempt >= retries || ctx.Err() != nil {
+			return
+		}
+		mb.Debugf("modbus: no response, retrying (%v/%v)", attempt+1, retries)
+	}
+}
+
+// retries returns the number of times a request of the function is sent
+// again after a timeout, none for the requests changing the state of the
+// device.
+func (mb *UdpPort) retries(functionCode byte) int {
+	if !idempotent(functionCode) {
+		return 0
+	}
+	return mb.Retries
+}
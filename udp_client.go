@@ -0,0 +1,139 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+)
+
+// UDPClientHandler implements Packager and Transporter interface for
+// Modbus TCP framing (MBAP) over UDP.
+type UDPClientHandler struct {
+	TcpPackager
+	udpTransporter
+}
+
+// NewUDPClientHandler allocates a new UDPClientHandler.
+func NewUDPClientHandler(address string) *UDPClientHandler {
+	h := &UDPClientHandler{}
+	h.Address = address
+	h.Timeout = udpTimeout
+	h.IdleTimeout = udpIdleTimeout
+	h.Retries = udpRetries
+	return h
+}
+
+// udpTransporter implements Transporter interface.
+type udpTransporter struct {
+	UdpPort
+}
+
+// Send sends the request in a datagram and waits for the response with the
+// same transaction id, discarding stale and duplicate datagrams.
+func (mb *udpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done.
+func (mb *udpTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	mb.Mu.Lock()
+	defer func() {
+		if mb.QueryDelayDuration > 0 {
+			time.Sleep(mb.QueryDelayDuration)
+		}
+		mb.Mu.Unlock()
+	}()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if !tcpResponseExpected(aduRequest) {
+		return mb.exchange(ctx, aduRequest, 0, nil)
+	}
+	return mb.exchange(ctx, aduRequest, mb.retries(aduRequest[tcpHeaderSize]), func(aduResponse []byte) bool {
+		return tcpResponseMatches(aduRequest, aduResponse)
+	})
+}
+
+// tcpResponseMatches reports whether aduResponse is a complete frame
+// answering aduRequest, as checked by TcpPackager.Verify.
+func tcpResponseMatches(aduRequest, aduResponse []byte) bool {
+	if len(aduResponse) <= tcpHeaderSize {
+		return false
+	}
+	// Length covers unit id and PDU
+	if int(binary.BigEndian.Uint16(aduResponse[4:])) != len(aduResponse)-tcpHeaderSize+1 {
+		return false
+	}
+	var packager TcpPackager
+	return packager.Verify(aduRequest, aduResponse) == nil
+}
+
+// RTUOverUDPClientHandler implements Packager and Transporter interface.
+type RTUOverUDPClientHandler struct {
+	RtuPackager
+	rtuOverUdpTransporter
+}
+
+// NewRTUOverUDPClientHandler allocates and initializes a RTUOverUDPClientHandler.
+func NewRTUOverUDPClientHandler(address string) *RTUOverUDPClientHandler {
+	handler := &RTUOverUDPClientHandler{}
+	handler.Address = address
+	handler.Timeout = udpTimeout
+	handler.IdleTimeout = udpIdleTimeout
+	handler.Retries = udpRetries
+	handler.TurnaroundDelay = serialTurnaroundDelay
+	return handler
+}
+
+// rtuOverUdpTransporter implements Transporter interface.
+type rtuOverUdpTransporter struct {
+	UdpPort
+	// Delay after a broadcast request, giving the slaves time to process it
+	TurnaroundDelay time.Duration
+}
+
+// Send sends the request in a datagram and waits for a response from the
+// same slave to the same function with a valid CRC. RTU frames carry no
+// transaction id, so a late response to a previous request of the same
+// function cannot be told apart.
+func (mb *rtuOverUdpTransporter) Send(aduRequest []byte) (aduResponse []byte, err error) {
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext is Send which aborts the transaction when ctx is done.
+func (mb *rtuOverUdpTransporter) SendContext(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	mb.Mu.Lock()
+	defer func() {
+		if mb.QueryDelayDuration > 0 {
+			time.Sleep(mb.QueryDelayDuration)
+		}
+		mb.Mu.Unlock()
+	}()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if calculateResponseLength(aduRequest) == 0 {
+		if _, err = mb.exchange(ctx, aduRequest, 0, nil); err == nil && aduRequest[0] == 0 {
+			err = sleepContext(ctx, mb.TurnaroundDelay)
+		}
+		return
+	}
+	return mb.exchange(ctx, aduRequest, mb.retries(aduRequest[1]), func(aduResponse []byte) bool {
+		return rtuResponseMatches(aduRequest, aduResponse)
+	})
+}
+
+// rtuResponseMatches reports whether aduResponse is a frame with a valid
+// CRC from the slave addressed by aduRequest, answering its function.
+func rtuResponseMatches(aduRequest, aduResponse []byte) bool {
+	length := len(aduResponse)
+	if length < rtuMinSize || aduResponse[0] != aduRequest[0] {
+		return false
+	}
+	if aduResponse[1] != aduRequest[1] && aduResponse[1] != aduRequest[1]|0x80 {
+		return false
+	}
+	var crc crc
+	crc.reset().pushBytes(aduResponse[0 : length-2])
+	return uint16(aduResponse[length-1])<<8|uint16(aduResponse[length-2]) == crc.value()
+}